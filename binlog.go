@@ -0,0 +1,553 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binlog event types
+const (
+	binlogQueryEvent             = 2
+	binlogFormatDescriptionEvent = 15
+	binlogXIDEvent               = 16
+	binlogTableMapEvent          = 19
+	binlogWriteRowsEvent         = 30
+	binlogUpdateRowsEvent        = 31
+	binlogDeleteRowsEvent        = 32
+	binlogGTIDEvent              = 33
+	binlogPreviousGTIDsEvent     = 35
+)
+
+const (
+	binlogMagic         = "\xfebin"
+	binlogVersion       = 4
+	binlogServerID      = 1
+	binlogHeaderSize    = 19
+	binlogChecksumSize  = 4
+	binlogChecksumCRC32 = 1
+	// id of the first table in the TABLE_MAP events
+	binlogFirstTableID = 100
+	// post header length of the GTID event
+	binlogGTIDPostHeader = 42
+	// flag of the last rows event of a statement
+	binlogStmtEndFlag = 1
+	// the GTID event of a transaction that is not row based (i.e. DDL)
+	binlogMayHaveSBRFlag = 1
+	// logical clock used by the GTID events
+	binlogLogicalTimestamp = 2
+	// status variables of the QUERY events
+	binlogQFlags2Code   = 0
+	binlogQSQLModeCode  = 1
+	binlogQCatalogCode  = 6
+	binlogQCharsetCode  = 4
+	binlogTableMapFlags = 1
+	// optional metadata of the TABLE_MAP events (binlog_row_metadata=FULL)
+	binlogSignednessMetadata     = 1
+	binlogDefaultCharsetMetadata = 2
+	binlogColumnNameMetadata     = 4
+	binlogPrimaryKeyMetadata     = 8
+
+	// column types of the generated tables
+	mysqlTypeLong = 3
+	mysqlTypeBlob = 252
+	// utf8mb4_0900_ai_ci
+	collationUTF8MB4 = 255
+
+	defaultBinlogServerUUID = "7e3c9a61-5a0e-11eb-9c8a-0242ac110002"
+)
+
+// binlogEpoch is the time of the first transaction. Every transaction is one
+// millisecond later than the previous one, so the same seed produces the same file.
+var binlogEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// binlogPostHeaderLengths are the post header lengths of the event types of MySQL 8.0.27
+var binlogPostHeaderLengths = []byte{
+	0x00, 0x0d, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x04, 0x00, 0x04, 0x00, 0x00, 0x00,
+	0x62, 0x00, 0x04, 0x1a, 0x08, 0x00, 0x00, 0x00, 0x08, 0x08, 0x08, 0x02, 0x00, 0x00,
+	0x00, 0x0a, 0x0a, 0x0a, 0x2a, 0x2a, 0x00, 0x12, 0x34, 0x00, 0x0a, 0x28, 0x00,
+}
+
+// binlogEvent is an event of a transaction before its header has been written
+type binlogEvent struct {
+	typ  byte
+	body []byte
+}
+
+// binlogWriter writes a row based binary log with GTIDs and CRC32 checksums
+type binlogWriter struct {
+	w   io.Writer
+	sid []byte
+	pos int64
+	// number of the next transaction. It is used for the GNO, the sequence number and the XID.
+	next   int64
+	events int64
+	// events of the open transaction
+	txn []binlogEvent
+}
+
+func newBinlogWriter(w io.Writer, sid []byte) (*binlogWriter, error) {
+	b := &binlogWriter{w: w, sid: sid, pos: int64(len(binlogMagic)), next: 1}
+	if _, err := io.WriteString(w, binlogMagic); err != nil {
+		return nil, err
+	}
+
+	fde := make([]byte, 0, 100)
+	fde = appendUint16(fde, binlogVersion)
+	version := make([]byte, 50)
+	copy(version, emulatedServerVersion)
+	fde = append(fde, version...)
+	fde = appendUint32(fde, 0)
+	fde = append(fde, binlogHeaderSize)
+	fde = append(fde, binlogPostHeaderLengths...)
+	fde = append(fde, binlogChecksumCRC32)
+	if err := b.write(binlogFormatDescriptionEvent, fde); err != nil {
+		return nil, err
+	}
+	// no GTIDs have been executed before this file
+	if err := b.write(binlogPreviousGTIDsEvent, make([]byte, 8)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// timestamp returns the commit time of the next transaction
+func (b *binlogWriter) timestamp() time.Time {
+	return binlogEpoch.Add(time.Duration(b.next-1) * time.Millisecond)
+}
+
+// write writes an event to the file
+func (b *binlogWriter) write(typ byte, body []byte) error {
+	size := binlogHeaderSize + len(body) + binlogChecksumSize
+	if b.pos+int64(size) > math.MaxUint32 {
+		return fmt.Errorf("the binlog exceeds the maximum size of 4GB. Reduce --size or --binlog-transactions")
+	}
+	b.pos += int64(size)
+	event := make([]byte, 0, size)
+	event = appendUint32(event, uint32(b.timestamp().Unix()))
+	event = append(event, typ)
+	event = appendUint32(event, binlogServerID)
+	event = appendUint32(event, uint32(size))
+	event = appendUint32(event, uint32(b.pos))
+	event = appendUint16(event, 0)
+	event = append(event, body...)
+	event = appendUint32(event, crc32.ChecksumIEEE(event))
+	b.events++
+	_, err := b.w.Write(event)
+	return err
+}
+
+// queryEvent returns the body of a QUERY event
+func queryEvent(database, query string) []byte {
+	var status []byte
+	status = append(status, binlogQFlags2Code)
+	status = appendUint32(status, 0)
+	status = append(status, binlogQSQLModeCode)
+	status = appendUint64(status, 0)
+	status = append(status, binlogQCatalogCode, 3)
+	status = append(status, "std"...)
+	status = append(status, binlogQCharsetCode)
+	for i := 0; i < 3; i++ {
+		status = appendUint16(status, collationUTF8MB4)
+	}
+
+	body := appendUint32(nil, 1) // thread id
+	body = appendUint32(body, 0) // execution time
+	body = append(body, byte(len(database)))
+	body = appendUint16(body, 0) // error code
+	body = appendUint16(body, uint16(len(status)))
+	body = append(body, status...)
+	body = append(body, database...)
+	body = append(body, 0)
+	return append(body, query...)
+}
+
+// ddl writes a DDL statement as a transaction of its own
+func (b *binlogWriter) ddl(database, query string) error {
+	b.txn = []binlogEvent{{typ: binlogQueryEvent, body: queryEvent(database, query)}}
+	return b.commit(binlogMayHaveSBRFlag, false)
+}
+
+// begin starts a transaction
+func (b *binlogWriter) begin() {
+	b.txn = []binlogEvent{{typ: binlogQueryEvent, body: queryEvent("", "BEGIN")}}
+}
+
+// rows adds a statement that changes a single row of a table to the open
+// transaction. before is nil for inserts and after is nil for deletes.
+func (b *binlogWriter) rows(database string, table int, before, after *generator.Row) {
+	b.txn = append(b.txn, binlogEvent{typ: binlogTableMapEvent, body: tableMapEvent(database, table)})
+
+	typ := byte(binlogUpdateRowsEvent)
+	switch {
+	case before == nil:
+		typ = binlogWriteRowsEvent
+	case after == nil:
+		typ = binlogDeleteRowsEvent
+	}
+	body := appendUint48(nil, binlogFirstTableID+uint64(table))
+	body = appendUint16(body, binlogStmtEndFlag)
+	body = appendUint16(body, 2) // length of the extra data, including itself
+	body = appendPackedInt(body, uint64(len(generator.Columns)))
+	// all the columns are present in the images
+	present := byte(1<<uint(len(generator.Columns)) - 1)
+	body = append(body, present)
+	if typ == binlogUpdateRowsEvent {
+		body = append(body, present)
+	}
+	for _, row := range []*generator.Row{before, after} {
+		if row != nil {
+			body = appendRowImage(body, row)
+		}
+	}
+	b.txn = append(b.txn, binlogEvent{typ: typ, body: body})
+}
+
+// commit writes the GTID event and the events of the open transaction. The
+// transactions of DML statements end with an XID event.
+func (b *binlogWriter) commit(flags byte, xid bool) error {
+	if xid {
+		b.txn = append(b.txn, binlogEvent{typ: binlogXIDEvent, body: appendUint64(nil, uint64(b.next))})
+	}
+
+	// the transaction length includes the GTID event, whose size depends on the length
+	length := int64(0)
+	for _, event := range b.txn {
+		length += int64(binlogHeaderSize + len(event.body) + binlogChecksumSize)
+	}
+	gtidSize := int64(binlogHeaderSize + binlogGTIDPostHeader + 7 + 4 + binlogChecksumSize)
+	n := 1
+	for len(appendPackedInt(nil, uint64(length+gtidSize+int64(n)))) != n {
+		n = len(appendPackedInt(nil, uint64(length+gtidSize+int64(n))))
+	}
+	length += gtidSize + int64(n)
+
+	gtid := []byte{flags}
+	gtid = append(gtid, b.sid...)
+	gtid = appendUint64(gtid, uint64(b.next))
+	gtid = append(gtid, binlogLogicalTimestamp)
+	gtid = appendUint64(gtid, uint64(b.next-1)) // last committed
+	gtid = appendUint64(gtid, uint64(b.next))   // sequence number
+	commitTime := uint64(b.timestamp().UnixNano() / int64(time.Microsecond))
+	gtid = append(gtid, appendUint64(nil, commitTime)[:7]...)
+	gtid = appendPackedInt(gtid, uint64(length))
+	gtid = appendUint32(gtid, emulatedServerVersionID)
+	if err := b.write(binlogGTIDEvent, gtid); err != nil {
+		return err
+	}
+	for _, event := range b.txn {
+		if err := b.write(event.typ, event.body); err != nil {
+			return err
+		}
+	}
+	b.txn = nil
+	b.next++
+	return nil
+}
+
+// gtidSet returns the GTIDs written to the file
+func (b *binlogWriter) gtidSet() string {
+	if b.next == 1 {
+		return ""
+	}
+	return fmt.Sprintf("%s:1-%d", formatUUID(b.sid), b.next-1)
+}
+
+// tableMapEvent returns the body of the TABLE_MAP event of a generated table
+func tableMapEvent(database string, table int) []byte {
+	name := fmt.Sprintf("table%d", table)
+	body := appendUint48(nil, binlogFirstTableID+uint64(table))
+	body = appendUint16(body, binlogTableMapFlags)
+	body = append(body, byte(len(database)))
+	body = append(body, database...)
+	body = append(body, 0, byte(len(name)))
+	body = append(body, name...)
+	body = append(body, 0)
+	// id, name, height, weight, age, description
+	body = appendPackedInt(body, uint64(len(generator.Columns)))
+	body = append(body, mysqlTypeLong, mysqlTypeBlob, mysqlTypeLong, mysqlTypeLong, mysqlTypeLong, mysqlTypeBlob)
+	// the metadata of the columns is prefixed with its length. Only the BLOB
+	// columns have metadata, the number of bytes of their length, which is 2 for TEXT
+	meta := []byte{2, 2}
+	body = appendPackedInt(body, uint64(len(meta)))
+	body = append(body, meta...)
+	// every column except id is nullable
+	body = append(body, 0x3e)
+
+	// the integer columns are signed
+	body = appendMetadata(body, binlogSignednessMetadata, []byte{0})
+	body = appendMetadata(body, binlogDefaultCharsetMetadata, appendPackedInt(nil, collationUTF8MB4))
+	var names []byte
+	for _, column := range generator.Columns {
+		names = appendPackedInt(names, uint64(len(column)))
+		names = append(names, column...)
+	}
+	body = appendMetadata(body, binlogColumnNameMetadata, names)
+	return appendMetadata(body, binlogPrimaryKeyMetadata, appendPackedInt(nil, 0))
+}
+
+// appendMetadata appends a field of the optional metadata of a TABLE_MAP event
+func appendMetadata(body []byte, typ byte, value []byte) []byte {
+	body = append(body, typ)
+	body = appendPackedInt(body, uint64(len(value)))
+	return append(body, value...)
+}
+
+// appendRowImage appends the values of the row in the binary row format
+func appendRowImage(body []byte, row *generator.Row) []byte {
+	body = append(body, 0) // no NULL values
+	body = appendUint32(body, uint32(row.ID))
+	body = appendUint16(body, uint16(len(row.Name)))
+	body = append(body, row.Name...)
+	for _, v := range []int{row.Height, row.Weight, row.Age} {
+		body = appendUint32(body, uint32(v))
+	}
+	body = appendUint16(body, uint16(len(row.Description)))
+	return append(body, row.Description...)
+}
+
+// appendPackedInt appends a length encoded integer
+func appendPackedInt(b []byte, v uint64) []byte {
+	switch {
+	case v < 251:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return appendUint16(append(b, 0xfc), uint16(v))
+	case v < 1<<24:
+		return append(b, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		return appendUint64(append(b, 0xfe), v)
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint48(b []byte, v uint64) []byte {
+	return append(b, appendUint64(nil, v)[:6]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// parseUUID parses a server UUID (i.e. 7e3c9a61-5a0e-11eb-9c8a-0242ac110002)
+func parseUUID(uuid string) ([]byte, error) {
+	sid, err := hex.DecodeString(strings.ReplaceAll(uuid, "-", ""))
+	if err != nil || len(sid) != 16 {
+		return nil, fmt.Errorf("invalid server UUID %q", uuid)
+	}
+	return sid, nil
+}
+
+func formatUUID(sid []byte) string {
+	s := hex.EncodeToString(sid)
+	return strings.Join([]string{s[:8], s[8:12], s[12:16], s[16:20], s[20:]}, "-")
+}
+
+// binlogSink logs every batch of the generator as a transaction of row events.
+// It is written by a single worker.
+type binlogSink struct {
+	b *binlogWriter
+	// keep the rows of every table by id
+	keep  bool
+	rows  [][]*generator.Row
+	ids   []int64
+	index map[string]int
+}
+
+func (s *binlogSink) CreateTables(ctx context.Context, database string, tables []string, overwrite bool) error {
+	if overwrite {
+		if err := s.b.ddl("", fmt.Sprintf("DROP DATABASE IF EXISTS %s", generator.QuoteIdentifier(database))); err != nil {
+			return err
+		}
+	}
+	if err := s.b.ddl("", fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", generator.QuoteIdentifier(database))); err != nil {
+		return err
+	}
+	s.rows, s.ids, s.index = make([][]*generator.Row, len(tables)), make([]int64, len(tables)), map[string]int{}
+	for i, table := range tables {
+		if err := s.b.ddl(database, generator.DumpTableStatement(table)); err != nil {
+			return err
+		}
+		s.index[table] = i
+	}
+	return nil
+}
+
+func (s *binlogSink) Write(database, table string, rows []generator.Row) error {
+	return s.WriteTables(database, []generator.TableRows{{Table: table, Rows: rows}})
+}
+
+// WriteTables logs the batch as a single transaction
+func (s *binlogSink) WriteTables(database string, batch []generator.TableRows) error {
+	s.b.begin()
+	for _, t := range batch {
+		i, ok := s.index[t.Table]
+		if !ok {
+			return fmt.Errorf("table %q has not been created", t.Table)
+		}
+		for j := range t.Rows {
+			s.ids[i]++
+			t.Rows[j].ID = s.ids[i]
+			s.b.rows(database, i, nil, &t.Rows[j])
+			if s.keep {
+				row := t.Rows[j]
+				s.rows[i] = append(s.rows[i], &row)
+			}
+		}
+	}
+	return s.b.commit(0, true)
+}
+
+// writeBinlog writes the events that a server would have logged in row based
+// format to --binlog-file, without connecting to the server: the DDL of the
+// tables and the inserts of --size worth of rows, which are the same rows as the
+// rows of a SQL dump with the same seed. With a workload mix, --binlog-transactions
+// updates, deletes and inserts picked by the mix are written afterwards. The reads
+// of the mix are not logged.
+func (opt *GeneratorOptions) writeBinlog(mix *workloadMix) error {
+	if mix != nil {
+		writes := false
+		for _, op := range mix.operations {
+			writes = writes || isWriteOperation(op.name)
+		}
+		if !writes {
+			return fmt.Errorf("workload mix %q does not contain any write operation to write to the binlog", opt.workloadMix)
+		}
+	}
+	sid, err := parseUUID(opt.binlogServerUUID)
+	if err != nil {
+		return err
+	}
+	genOpts, err := opt.offlineOptions()
+	if err != nil {
+		return err
+	}
+	f, err := createOutputFile(opt.binlogFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	startingTime := time.Now()
+	out.phase("binlog", "Writing binlog of database %q to %q.....", opt.dbName, opt.binlogFile)
+	b, err := newBinlogWriter(f, sid)
+	if err != nil {
+		return err
+	}
+	// the rows are only kept for the updates and deletes of the workload
+	sink := &binlogSink{b: b, keep: mix != nil}
+	result, err := opt.generateOffline(sink, genOpts, "Logged")
+	if err != nil {
+		return err
+	}
+	seed := result.Seed
+	tables := sink.rows
+	inserted := result.Rows
+	var updated, deleted int64
+
+	if mix != nil {
+		// the workload has its own stream, apart from the stream of the single worker of the inserts
+		r := rand.New(rand.NewSource(generator.BatchSeed(seed, opt.tableNumber, 0)))
+		inserts := false
+		for _, op := range mix.operations {
+			inserts = inserts || op.name == OpInsert
+		}
+		for written := int64(0); written < opt.binlogTransactions; {
+			// without inserts, the updates and deletes can not change any row once the tables are empty
+			if !inserts && inserted == deleted {
+				return fmt.Errorf("workload mix %q can not change any row after %d of %d transactions as the tables are empty", opt.workloadMix, written, opt.binlogTransactions)
+			}
+			table := r.Intn(opt.tableNumber)
+			op := mix.pick(r)
+			maxID := int64(len(tables[table]))
+			id := int64(1)
+			if maxID > 0 {
+				id = 1 + r.Int63n(maxID)
+			}
+			// statements that do not change any row are not logged
+			var current *generator.Row
+			if id <= maxID {
+				current = tables[table][id-1]
+			}
+			switch op {
+			case OpInsert:
+				row := generator.NewRow(r)
+				row.ID = maxID + 1
+				tables[table] = append(tables[table], &row)
+				b.begin()
+				b.rows(opt.dbName, table, nil, &row)
+				inserted++
+			case OpUpdate:
+				column, value := randomColumnValue(r)
+				if current == nil {
+					continue
+				}
+				row := *current
+				row.Set(column, value)
+				if row == *current {
+					continue
+				}
+				b.begin()
+				b.rows(opt.dbName, table, current, &row)
+				tables[table][id-1] = &row
+				updated++
+			case OpDelete:
+				if current == nil {
+					continue
+				}
+				b.begin()
+				b.rows(opt.dbName, table, current, nil)
+				tables[table][id-1] = nil
+				deleted++
+			default:
+				continue
+			}
+			if err := b.commit(0, true); err != nil {
+				return err
+			}
+			written++
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write binlog %q. Reason: %v", opt.binlogFile, err)
+	}
+
+	info, err := os.Stat(opt.binlogFile)
+	if err != nil {
+		return err
+	}
+	out.text("\n=========================== Summery ===========================\n")
+	out.summary([]summaryField{
+		{key: "transactions", title: "Transactions", text: strconv.FormatInt(b.next-1, 10), value: b.next - 1},
+		{key: "events", title: "Events", text: strconv.FormatInt(b.events, 10), value: b.events},
+		{key: "rowsInserted", title: "Rows inserted", text: strconv.FormatInt(inserted, 10), value: inserted},
+		{key: "rowsUpdated", title: "Rows updated", text: strconv.FormatInt(updated, 10), value: updated},
+		{key: "rowsDeleted", title: "Rows deleted", text: strconv.FormatInt(deleted, 10), value: deleted},
+		{key: "gtidSet", title: "GTID set", text: b.gtidSet(), value: b.gtidSet()},
+		{key: "fileBytes", title: "Binlog file size", text: formatSize(int(info.Size())), value: info.Size()},
+		{key: "durationSeconds", title: "Total time taken", text: time.Since(startingTime).String(), value: time.Since(startingTime).Seconds()},
+		{key: "seed", title: "Seed", text: strconv.FormatInt(seed, 10), value: seed},
+	})
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// CheckpointTable makes --checkpoint store the checkpoint in the target database
+	CheckpointTable = "table"
+
+	checkpointTableName = "_generator_checkpoint"
+)
+
+// checkpoint is the persisted progress of a generation run. It is enough to
+// continue an interrupted run deterministically with --resume.
+type checkpoint struct {
+	Database string `json:"database"`
+	Seed     int64  `json:"seed"`
+	// InitialSize is the size of the database before the first run started. It is
+	// kept so that a resumed run does not overshoot the desired amount of data.
+	InitialSize    int     `json:"initialSize"`
+	BytesGenerated int64   `json:"bytesGenerated"`
+	RowsGenerated  int64   `json:"rowsGenerated"`
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	// Concurrency is the number of workers of the run. A resumed run must have
+	// the same number of workers to continue the batches of each of them.
+	Concurrency int `json:"concurrency"`
+	// Workers holds the number of batches committed by each worker. The rows of
+	// a batch are generated from a seed derived from the seed, the worker and the
+	// batch number, so a resumed worker continues exactly where it stopped.
+	Workers   []int64 `json:"workers"`
+	Completed bool    `json:"completed"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+// progressTracker keeps the checkpoint of the current run up to date
+type progressTracker struct {
+	mu        sync.Mutex
+	state     checkpoint
+	resumedAt time.Time
+	// elapsed time of the previous runs
+	previous time.Duration
+}
+
+var progress = &progressTracker{}
+
+// init initializes the tracker for a new run or from the checkpoint of an interrupted run
+func (p *progressTracker) init(cp *checkpoint, dbName string, seed int64, initialSize, concurrency int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resumedAt = time.Now()
+	if cp != nil {
+		p.state = *cp
+		p.previous = time.Duration(cp.ElapsedSeconds * float64(time.Second))
+	} else {
+		p.state = checkpoint{Database: dbName, Seed: seed, InitialSize: initialSize}
+	}
+	p.state.Concurrency = concurrency
+}
+
+// commit records a committed batch of a worker
+func (p *progressTracker) commit(batch generator.Batch) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.state.Workers) <= batch.Worker {
+		p.state.Workers = append(p.state.Workers, 0)
+	}
+	p.state.Workers[batch.Worker]++
+	p.state.RowsGenerated += batch.Rows
+	p.state.BytesGenerated += batch.Bytes
+}
+
+// elapsed returns the total time spent by this and the previous runs
+func (p *progressTracker) elapsed() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.previous + time.Since(p.resumedAt)
+}
+
+func (p *progressTracker) snapshot(completed bool) checkpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cp := p.state
+	cp.ElapsedSeconds = (p.previous + time.Since(p.resumedAt)).Seconds()
+	cp.Completed = completed
+	cp.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	cp.Workers = append([]int64(nil), p.state.Workers...)
+	return cp
+}
+
+// saveCheckpoint persists the current progress to the checkpoint file or table
+func (opt *GeneratorOptions) saveCheckpoint(completed bool) error {
+	if opt.checkpoint == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(progress.snapshot(completed), "", "  ")
+	if err != nil {
+		return err
+	}
+	if opt.checkpoint == CheckpointTable {
+		_, err = db.Exec(fmt.Sprintf("REPLACE INTO %s (id, data) VALUES (1, ?)", checkpointTableName), string(data))
+		return err
+	}
+	// write to a temporary file first so that a crash never leaves a partial checkpoint behind
+	tmp := opt.checkpoint + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, opt.checkpoint)
+}
+
+// loadCheckpoint returns the persisted checkpoint or nil if there is none
+func (opt *GeneratorOptions) loadCheckpoint() (*checkpoint, error) {
+	var data []byte
+	if opt.checkpoint == CheckpointTable {
+		err := db.QueryRow(fmt.Sprintf("SELECT data FROM %s WHERE id = 1", checkpointTableName)).Scan(&data)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		data, err = ioutil.ReadFile(filepath.Clean(opt.checkpoint))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cp := &checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint. Reason: %v", err)
+	}
+	if cp.Database != opt.dbName {
+		return nil, fmt.Errorf("checkpoint belongs to database %q, not %q", cp.Database, opt.dbName)
+	}
+	// the checkpoints of older versions do not have the concurrency, but they
+	// have at most one entry per worker
+	switch {
+	case cp.Completed:
+	case cp.Concurrency != 0 && cp.Concurrency != opt.concurrency:
+		return nil, fmt.Errorf("checkpoint has been written with a concurrency of %d. Resume with --concurrency=%d to continue the batches of every worker", cp.Concurrency, cp.Concurrency)
+	case cp.Concurrency == 0 && len(cp.Workers) > opt.concurrency:
+		return nil, fmt.Errorf("checkpoint holds the batches of %d workers. Resume with the --concurrency of the interrupted run to continue the batches of every worker", len(cp.Workers))
+	}
+	return cp, nil
+}
+
+// ensureCheckpointTable creates the metadata table that stores the checkpoint
+func (opt *GeneratorOptions) ensureCheckpointTable() error {
+	if opt.checkpoint != CheckpointTable {
+		return nil
+	}
+	_, err := db.Exec(checkpointTableStatement())
+	return err
+}
+
+func checkpointTableStatement() string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id int NOT NULL PRIMARY KEY, data longtext, updated_at timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP)", checkpointTableName)
+}
+
+// checkpointPeriodically persists the progress every --checkpoint-interval until the context is done
+func (opt *GeneratorOptions) checkpointPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(opt.checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := opt.saveCheckpoint(false); err != nil {
+				out.error(err, "Failed to save the checkpoint")
+			}
+		}
+	}
+}
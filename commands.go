@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// commands
+const (
+	CommandGenerate    = "generate"
+	CommandWorkload    = "workload"
+	CommandStress      = "stress"
+	CommandVerify      = "verify"
+	CommandCheckLedger = "check-ledger"
+	CommandClean       = "clean"
+	CommandStats       = "stats"
+	CommandSchema      = "schema"
+)
+
+// command is a subcommand of the generator. The command is the first argument,
+// followed by the flags (i.e. "mysql-data-generator clean --database=sampleData").
+type command struct {
+	name        string
+	aliases     []string
+	description string
+	run         func(opt *GeneratorOptions) error
+}
+
+var commands = []command{
+	{name: CommandGenerate, description: "Insert random data until the desired size or duration is reached (default)", run: (*GeneratorOptions).generateData},
+	{name: CommandWorkload, description: "Run a mixed OLTP workload against the generated tables", run: (*GeneratorOptions).runWorkload},
+	{name: CommandStress, description: "Stress the binlog and replication size limits", run: (*GeneratorOptions).runStress},
+	{name: CommandVerify, aliases: []string{CommandCheckLedger}, description: "Confirm that every row recorded in the --ledger exists in the database", run: (*GeneratorOptions).checkLedger},
+	{name: CommandClean, description: "Drop the generated tables, and the database if nothing else is left in it", run: (*GeneratorOptions).clean},
+	{name: CommandStats, description: "Show the size of the databases and of the tables of --database", run: (*GeneratorOptions).showStats},
+	{name: CommandSchema, description: "Print the DDL of the database and the tables that would be created", run: (*GeneratorOptions).printSchema},
+}
+
+// generatedTable matches the names of the tables created by the generator
+var generatedTable = regexp.MustCompile(fmt.Sprintf("^(table[0-9]+|%s|%s|%s)$", stressLargeRowTable, stressWideTable, checkpointTableName))
+
+// lookupCommand returns the command with the given name or alias
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+		for _, alias := range commands[i].aliases {
+			if alias == name {
+				return &commands[i]
+			}
+		}
+	}
+	return nil
+}
+
+// parseCommand returns the command given by the first argument and the
+// remaining arguments. The command is nil if the first argument is a flag.
+func parseCommand(args []string) (*command, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return nil, args, nil
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		return nil, nil, fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd, args[1:], nil
+}
+
+// modeCommand returns the command of the deprecated --mode flag
+func modeCommand(mode string) (*command, error) {
+	switch mode {
+	case ModeGenerate, ModeWorkload, ModeStress:
+		return lookupCommand(mode), nil
+	default:
+		return nil, fmt.Errorf("unknown mode %q. Expected one of (generate, workload, stress)", mode)
+	}
+}
+
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		name := cmd.name
+		if len(cmd.aliases) > 0 {
+			name = fmt.Sprintf("%s (%s)", cmd.name, strings.Join(cmd.aliases, ", "))
+		}
+		fmt.Fprintf(w, "  %-24s %s\n", name, cmd.description)
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// listTables returns the tables of the database
+func listTables(database string) ([]string, error) {
+	rows, err := db.Query("SELECT table_name FROM information_schema.TABLES WHERE table_schema = ? ORDER BY table_name", database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// clean drops the tables created by the generator. The database is dropped too
+// if no other table is left in it.
+func (opt *GeneratorOptions) clean() error {
+	var err error
+	db, err = opt.getClient("")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tables, err := listTables(opt.dbName)
+	if err != nil {
+		return err
+	}
+	out.phase("cleaning", "Dropping the generated tables of database %q.....", opt.dbName)
+	var remaining []string
+	for _, table := range tables {
+		if !generatedTable.MatchString(table) {
+			remaining = append(remaining, table)
+			continue
+		}
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + generator.QuoteIdentifier(opt.dbName) + "." + generator.QuoteIdentifier(table)); err != nil {
+			return fmt.Errorf("failed to drop table %q. Reason: %v", table, err)
+		}
+		out.info("Dropped table %q", table)
+	}
+	if len(remaining) > 0 {
+		out.info("Keeping database %q as it has tables that have not been created by the generator: %s", opt.dbName, strings.Join(remaining, ", "))
+		return nil
+	}
+	if _, err := db.Exec("DROP DATABASE IF EXISTS " + generator.QuoteIdentifier(opt.dbName)); err != nil {
+		return err
+	}
+	out.info("Dropped database %q", opt.dbName)
+	return nil
+}
+
+// showStats shows the size of every database and of each table of --database
+func (opt *GeneratorOptions) showStats() error {
+	var err error
+	db, err = opt.getClient("")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	out.text("\n====================== Current Database Sizes =================\n")
+	if err := opt.showDBSizes(); err != nil {
+		return err
+	}
+	tables, err := listTables(opt.dbName)
+	if err != nil {
+		return err
+	}
+	out.text("\n====================== Table Sizes (%s) =================\n", opt.dbName)
+	for _, table := range tables {
+		if err := opt.showTableSize(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaStatements returns the DDL statements of the database and the tables created by generate
+func (opt *GeneratorOptions) schemaStatements() []string {
+	statements := []string{fmt.Sprintf("CREATE DATABASE %s;", opt.dbName)}
+	for i := 0; i < opt.tableNumber; i++ {
+		statements = append(statements, generator.CreateTableStatement(fmt.Sprintf("table%d", i))+";")
+	}
+	if opt.checkpoint == CheckpointTable {
+		statements = append(statements, checkpointTableStatement()+";")
+	}
+	return statements
+}
+
+// printSchema prints the DDL of the database and the tables without connecting to the server
+func (opt *GeneratorOptions) printSchema() error {
+	statements := opt.schemaStatements()
+	if out.json {
+		out.emit(EventSchema, event{"database": opt.dbName, "statements": statements})
+		return nil
+	}
+	for _, statement := range statements {
+		out.printf("%s\n", statement)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// key of the section of the config file that holds the named profiles
+const profilesKey = "profiles"
+
+// builtinProfiles are the profiles available without a config file. A profile of
+// the config file with the same name replaces the builtin one.
+var builtinProfiles = map[string]map[string]string{
+	"small-smoke": {
+		"size":            "64MB",
+		"tables":          "2",
+		"concurrency":     "4",
+		"report-interval": "5s",
+	},
+	"5gb-backup-test": {
+		"size":        "5GB",
+		"tables":      "10",
+		"concurrency": "50",
+		"txn-rows":    "100",
+	},
+}
+
+// configFile is a parsed --config file. The sections other than profiles only
+// group the options, so each option is stored by its name.
+type configFile struct {
+	options  map[string]string
+	profiles map[string]map[string]string
+}
+
+// configEntry is a single option of a config file along with the sections it belongs to
+type configEntry struct {
+	path  []string
+	value string
+	line  int
+}
+
+// loadConfig applies the options of --config and --profile to the flags that
+// have not been set on the command line. The options of the profile take
+// precedence over the other options of the config file.
+func loadConfig() error {
+	if opt.config == "" && opt.profile == "" {
+		return nil
+	}
+
+	cfg := &configFile{options: map[string]string{}, profiles: map[string]map[string]string{}}
+	if opt.config != "" {
+		var err error
+		cfg, err = readConfigFile(opt.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	options := map[string]string{}
+	for name, value := range cfg.options {
+		options[name] = value
+	}
+	if opt.profile != "" {
+		profile, ok := cfg.profiles[opt.profile]
+		if !ok {
+			profile, ok = builtinProfiles[opt.profile]
+		}
+		if !ok {
+			return fmt.Errorf("unknown profile %q. Available profiles: %s", opt.profile, strings.Join(cfg.profileNames(), ", "))
+		}
+		for name, value := range profile {
+			options[name] = value
+		}
+	}
+
+	// flags set on the command line take precedence
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for name, value := range options {
+		if explicit[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for option %q in config. Reason: %v", value, name, err)
+		}
+	}
+	return nil
+}
+
+// profileNames returns the names of the builtin profiles and the profiles of the config file
+func (c *configFile) profileNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, profiles := range []map[string]map[string]string{builtinProfiles, c.profiles} {
+		for name := range profiles {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readConfigFile reads a config file. Files with the .toml extension are read as
+// TOML, everything else as YAML. Only the subset of the formats needed for the
+// options is supported: nested sections of scalar values.
+func readConfigFile(path string) (*configFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config %q. Reason: %v", path, err)
+	}
+	defer file.Close()
+
+	var entries []configEntry
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		entries, err = parseTOML(file)
+	} else {
+		entries, err = parseYAML(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %q. Reason: %v", path, err)
+	}
+
+	cfg := &configFile{options: map[string]string{}, profiles: map[string]map[string]string{}}
+	for _, entry := range entries {
+		options := cfg.options
+		if entry.path[0] == profilesKey {
+			if len(entry.path) < 3 {
+				return nil, fmt.Errorf("invalid profile option at line %d of config %q", entry.line, path)
+			}
+			name := entry.path[1]
+			if cfg.profiles[name] == nil {
+				cfg.profiles[name] = map[string]string{}
+			}
+			options = cfg.profiles[name]
+		}
+		name := optionName(entry.path[len(entry.path)-1])
+		switch {
+		case name == "config" || name == "profile":
+			return nil, fmt.Errorf("option %q at line %d of config %q can only be set on the command line", name, entry.line, path)
+		case flag.Lookup(name) == nil:
+			return nil, fmt.Errorf("unknown option %q at line %d of config %q", name, entry.line, path)
+		}
+		options[name] = entry.value
+	}
+	return cfg, nil
+}
+
+// optionName returns the flag name of an option. Options may be written with
+// underscores instead of dashes (i.e. max_errors).
+func optionName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// parseYAML parses a YAML document of nested mappings with scalar values
+func parseYAML(file *os.File) ([]configEntry, error) {
+	type section struct {
+		indent int
+		key    string
+	}
+	var entries []configEntry
+	var sections []section
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := stripComment(scanner.Text())
+		if strings.TrimSpace(text) == "" || strings.TrimSpace(text) == "---" {
+			continue
+		}
+		if strings.Contains(text, "\t") {
+			return nil, fmt.Errorf("tabs are not allowed for indentation at line %d", line)
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		text = strings.TrimSpace(text)
+		i := strings.Index(text, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("expected \"key: value\" at line %d", line)
+		}
+		key, value := unquoteOption(strings.TrimSpace(text[:i])), strings.TrimSpace(text[i+1:])
+
+		// leave the sections that are not indented more than this line
+		for len(sections) > 0 && sections[len(sections)-1].indent >= indent {
+			sections = sections[:len(sections)-1]
+		}
+		if value == "" {
+			sections = append(sections, section{indent: indent, key: key})
+			continue
+		}
+		path := make([]string, 0, len(sections)+1)
+		for _, s := range sections {
+			path = append(path, s.key)
+		}
+		entries = append(entries, configEntry{path: append(path, key), value: unquoteOption(value), line: line})
+	}
+	return entries, scanner.Err()
+}
+
+// parseTOML parses a TOML document of tables with scalar values
+func parseTOML(file *os.File) ([]configEntry, error) {
+	var entries []configEntry
+	var table []string
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			table = nil
+			for _, key := range strings.Split(strings.Trim(text, "[]"), ".") {
+				table = append(table, unquoteOption(strings.TrimSpace(key)))
+			}
+			continue
+		}
+		i := strings.Index(text, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("expected \"key = value\" at line %d", line)
+		}
+		key, value := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		path := append(append([]string(nil), table...), unquoteOption(key))
+		entries = append(entries, configEntry{path: path, value: unquoteOption(value), line: line})
+	}
+	return entries, scanner.Err()
+}
+
+// stripComment removes the comment that starts with # outside of quotes
+func stripComment(text string) string {
+	var quote rune
+	for i, c := range text {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
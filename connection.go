@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"io/ioutil"
+	"net"
+	"strconv"
+)
+
+// name the server public key is registered with in the driver
+const serverPubKeyName = "generator"
+
+// connection attributes that identify the connections of the generator
+const defaultConnAttributes = "program_name:mysql-data-generator"
+
+// mysqlConfig returns the driver configuration to connect to the given database.
+// The configuration is built from --dsn if provided, otherwise from the host,
+// port or socket and the credentials. The connection flags that have been set
+// explicitly override the parameters of the DSN.
+func (opt *GeneratorOptions) mysqlConfig(database string) (*mysql.Config, error) {
+	cfg := mysql.NewConfig()
+	if opt.dsn != "" {
+		var err error
+		cfg, err = mysql.ParseDSN(opt.dsn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dsn. Reason: %v", err)
+		}
+		if isFlagSet("host") || isFlagSet("port") {
+			cfg.Net = "tcp"
+			cfg.Addr = net.JoinHostPort(opt.host, strconv.Itoa(opt.port))
+		}
+	} else {
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(opt.host, strconv.Itoa(opt.port))
+	}
+	// the database is chosen by the generator, not by the DSN
+	cfg.DBName = database
+
+	if cfg.User == "" || isFlagSet("user") {
+		cfg.User = opt.user
+	}
+	if cfg.Passwd == "" || isFlagSet("password") || isFlagSet("password-file") {
+		cfg.Passwd = opt.password
+	}
+	if opt.socket != "" {
+		cfg.Net = "unix"
+		cfg.Addr = opt.socket
+	}
+
+	if opt.charset != "" {
+		if cfg.Params == nil {
+			cfg.Params = map[string]string{}
+		}
+		cfg.Params["charset"] = opt.charset
+	}
+	if opt.collation != "" {
+		cfg.Collation = opt.collation
+	}
+	if cfg.ConnectionAttributes == "" || isFlagSet("connection-attributes") {
+		cfg.ConnectionAttributes = opt.connAttributes
+	}
+	if isFlagSet("connect-timeout") {
+		cfg.Timeout = opt.connectTimeout
+	}
+	if isFlagSet("read-timeout") {
+		cfg.ReadTimeout = opt.readTimeout
+	}
+	if isFlagSet("write-timeout") {
+		cfg.WriteTimeout = opt.writeTimeout
+	}
+	if isFlagSet("interpolate-params") {
+		cfg.InterpolateParams = opt.interpolateParams
+	}
+
+	if err := opt.configureTLS(cfg); err != nil {
+		return nil, err
+	}
+	if opt.serverPubKey != "" {
+		key, err := readServerPubKey(opt.serverPubKey)
+		if err != nil {
+			return nil, err
+		}
+		mysql.RegisterServerPubKey(serverPubKeyName, key)
+		cfg.ServerPubKey = serverPubKeyName
+	}
+	return cfg, nil
+}
+
+// readServerPubKey reads the RSA public key of the server used by the
+// caching_sha2_password and sha256_password authentication over unencrypted connections
+func readServerPubKey(path string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server public key %q. Reason: %v", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("server public key %q is not PEM encoded", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server public key %q. Reason: %v", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("server public key %q is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
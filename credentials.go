@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// option file groups read by the generator, in the order of precedence
+var optionFileGroups = []string{"mysql-data-generator", "client"}
+
+// loadCredentials fills the user and the password that have not been provided by
+// --user and --password. They are looked up in --password-file, --secret-dir,
+// --defaults-file and the environment, in this order.
+func (opt *GeneratorOptions) loadCredentials() error {
+	if opt.password == "" && opt.passwordFile != "" {
+		password, err := readSecretFile(opt.passwordFile)
+		if err != nil {
+			return fmt.Errorf("failed to read password file. Reason: %v", err)
+		}
+		opt.password = password
+	}
+
+	// kubernetes secret mounted as a directory with one file per key
+	if opt.secretDir != "" {
+		for _, key := range []struct {
+			names []string
+			value *string
+		}{
+			{names: []string{"username", "user"}, value: &opt.user},
+			{names: []string{"password"}, value: &opt.password},
+		} {
+			if *key.value != "" {
+				continue
+			}
+			for _, name := range key.names {
+				value, err := readSecretFile(filepath.Join(opt.secretDir, name))
+				if os.IsNotExist(err) {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("failed to read secret. Reason: %v", err)
+				}
+				*key.value = value
+				break
+			}
+		}
+	}
+
+	if opt.defaultsFile != "" {
+		options, err := readOptionFile(opt.defaultsFile, optionFileGroups)
+		if err != nil {
+			return err
+		}
+		if err := opt.applyOptionFile(options); err != nil {
+			return fmt.Errorf("invalid option file %q. Reason: %v", opt.defaultsFile, err)
+		}
+	}
+
+	// USERNAME and PASSWORD are kept for the existing deployments
+	if opt.user == "" {
+		opt.user = firstEnv("MYSQL_USER", "USERNAME")
+	}
+	if opt.password == "" {
+		opt.password = firstEnv("MYSQL_PWD", "PASSWORD")
+	}
+	return nil
+}
+
+// applyOptionFile sets the connection options of the option file that have not
+// been provided by the flags
+func (opt *GeneratorOptions) applyOptionFile(options map[string]string) error {
+	if v, ok := options["user"]; ok && opt.user == "" {
+		opt.user = v
+	}
+	if v, ok := options["password"]; ok && opt.password == "" {
+		opt.password = v
+	}
+	if v, ok := options["host"]; ok && !isFlagSet("host") {
+		opt.host = v
+	}
+	if v, ok := options["socket"]; ok && !isFlagSet("socket") {
+		opt.socket = v
+	}
+	if v, ok := options["port"]; ok && !isFlagSet("port") {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid port %q", v)
+		}
+		opt.port = port
+	}
+	return nil
+}
+
+// readOptionFile reads the options of the given groups of a MySQL option file
+// (i.e. ~/.my.cnf). The options of an earlier group take precedence.
+func readOptionFile(path string, groups []string) (map[string]string, error) {
+	file, err := os.Open(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open option file %q. Reason: %v", path, err)
+	}
+	defer file.Close()
+
+	// options per group
+	found := map[string]map[string]string{}
+	group := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"), strings.HasPrefix(line, "!"):
+			// comments and !include directives
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			group = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		key, value := line, ""
+		if i := strings.Index(line, "="); i >= 0 {
+			key, value = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+		// dashes and underscores are interchangeable in option names
+		key = strings.ReplaceAll(strings.ToLower(key), "_", "-")
+		if found[group] == nil {
+			found[group] = map[string]string{}
+		}
+		found[group][key] = unquoteOption(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	options := map[string]string{}
+	for i := len(groups) - 1; i >= 0; i-- {
+		for key, value := range found[groups[i]] {
+			options[key] = value
+		}
+	}
+	return options, nil
+}
+
+// unquoteOption removes the quotes around a value of an option file
+func unquoteOption(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// readSecretFile returns the content of the file without the trailing newline
+func readSecretFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(expandHome(path))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// firstEnv returns the value of the first environment variable that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InnoDB storage model of the generated rows. The defaults of MySQL 8.0 are
+// assumed: 16KB pages and the DYNAMIC row format.
+const (
+	innodbPageSize = 16 * OneKB
+	// record header (5), transaction id (6) and roll pointer (7)
+	innodbRecordOverhead = 18
+	// a record larger than this stores its longest variable length columns off-page
+	innodbMaxInlineRecord = 8126
+	// size of the pointer left in the record for a column stored off-page
+	innodbExternPointer = 20
+	// usable bytes of a page storing an off-page column
+	innodbBlobPageCapacity = innodbPageSize - 38 - 8 - 12
+	// clustered index pages are filled up to 15/16 on inserts in primary key order
+	innodbFillFactor = 15.0 / 16
+
+	// size of the Write_rows event headers of a row in the binary log
+	binlogRowOverhead = 35
+	// size of the Table_map event written for every statement, excluding the names
+	binlogTableMapOverhead = 40
+	// size of the GTID, BEGIN and XID events written for every transaction
+	binlogTxnOverhead = 190
+
+	// duration of the calibration benchmark of the dry run
+	calibrationDuration = 3 * time.Second
+	// maximum number of connections used by the calibration benchmark
+	calibrationMaxWorkers = 16
+	// number of generated statements sampled to measure the average statement size
+	planSamples = 1000
+)
+
+// rowEstimate is the expected size of a generated row
+type rowEstimate struct {
+	// bytes of the column values
+	data float64
+	// bytes used on the disk by InnoDB
+	disk float64
+	// bytes written to the binary log in row format
+	binlog float64
+	// bytes of the insert statement
+	statement float64
+}
+
+// estimateRow computes the expected size of a generated row from the schema of
+// the tables and the value generators
+func (opt *GeneratorOptions) estimateRow() rowEstimate {
+	name := generator.MeanNameLength()
+	description := float64(len(generator.LoremIpsum))
+
+	// id, height, weight and age are 4 byte integers. name and description are
+	// TEXT columns with a 2 byte length prefix in the binary log.
+	data := 4*4 + name + description
+
+	// the record has a null bitmap and 2 byte lengths of the variable length columns
+	record := innodbRecordOverhead + 1 + 2*2 + 4*4 + name
+	offPage := 0.0
+	if record+description > innodbMaxInlineRecord {
+		record += innodbExternPointer
+		offPage = math.Ceil(description/innodbBlobPageCapacity) * innodbPageSize
+	} else {
+		record += description
+	}
+
+	tableName := len(fmt.Sprintf("table%d", opt.tableNumber))
+	binlog := 1 + 4*4 + name + 2 + description + 2 + binlogRowOverhead + binlogTableMapOverhead + float64(len(opt.dbName)+tableName)
+
+	// sample the statements to measure the size counted by --rate
+	r := rand.New(rand.NewSource(1))
+	var statement float64
+	for i := 0; i < planSamples; i++ {
+		statement += float64(len(generator.InsertStatement("table0", generator.NewRow(r))))
+	}
+	statement = statement/planSamples + float64(tableName-len("table0"))
+
+	return rowEstimate{
+		data:      data,
+		disk:      record/innodbFillFactor + offPage,
+		binlog:    binlog,
+		statement: statement,
+	}
+}
+
+// planRun prints the plan of the run without creating anything: the expected
+// number of rows, the disk usage and the duration estimated by a short calibration
+// benchmark against the server.
+func (opt *GeneratorOptions) planRun() error {
+	out.phase("planning", "Planning the run (dry run).....")
+	row := opt.estimateRow()
+
+	var err error
+	if opt.rate != "" {
+		limiter, err = parseRate(opt.rate)
+		if err != nil {
+			return err
+		}
+	}
+
+	// throughput measured by the calibration benchmark, capped by --rate
+	rowsPerSecond, calibrationErr := opt.calibrate()
+	if limiter != nil {
+		limit := limiter.rate
+		if limiter.unit == RateBytes {
+			limit = limiter.rate / row.statement
+		}
+		if calibrationErr != nil || limit < rowsPerSecond {
+			rowsPerSecond, calibrationErr = limit, nil
+		}
+	}
+
+	var rows float64
+	var duration time.Duration
+	switch {
+	case opt.duration == 0 || isFlagSet("size"):
+		desiredAmount, err := opt.parseSize()
+		if err != nil {
+			return err
+		}
+		rows = math.Ceil(float64(desiredAmount) / row.disk)
+		if calibrationErr == nil {
+			duration = time.Duration(rows / rowsPerSecond * float64(time.Second))
+			if opt.duration > 0 && duration > opt.duration {
+				// the run stops at the duration before reaching the size
+				duration = opt.duration
+				rows = math.Floor(rowsPerSecond * opt.duration.Seconds())
+			}
+		}
+	case calibrationErr == nil:
+		duration = opt.duration
+		rows = math.Floor(rowsPerSecond * opt.duration.Seconds())
+	default:
+		return fmt.Errorf("can not estimate the rows of a time bounded run without the throughput. Reason: %v", calibrationErr)
+	}
+
+	txnRows := float64(opt.txnRows)
+	if txnRows < 1 {
+		txnRows = 1
+	}
+	disk := rows * row.disk
+	binlog := rows*row.binlog + math.Ceil(rows/txnRows)*binlogTxnOverhead
+	rowsPerTable := math.Ceil(rows / float64(opt.tableNumber))
+
+	throughputText, durationText := "unknown", "unknown ("+fmt.Sprint(calibrationErr)+")"
+	var throughputValue, durationValue interface{}
+	if calibrationErr == nil {
+		throughputText, throughputValue = fmt.Sprintf("%.0f rows/s", rowsPerSecond), rowsPerSecond
+		durationText, durationValue = duration.Round(time.Second).String(), duration.Seconds()
+	}
+	out.text("\n=========================== Plan ===========================\n")
+	out.fields(EventPlan, []summaryField{
+		{key: "rows", title: "Rows", text: strconv.FormatInt(int64(rows), 10), value: int64(rows)},
+		{key: "rowsPerTable", title: fmt.Sprintf("Rows per table (%d tables)", opt.tableNumber), text: strconv.FormatInt(int64(rowsPerTable), 10), value: int64(rowsPerTable)},
+		{key: "rowDataBytes", title: "Row data size", text: formatSize(int(row.data)), value: int64(row.data)},
+		{key: "rowDiskBytes", title: "Row size on disk", text: formatSize(int(row.disk)), value: int64(row.disk)},
+		{key: "dataBytes", title: "Data size", text: formatSize(int(rows * row.data)), value: int64(rows * row.data)},
+		{key: "diskBytes", title: "InnoDB size on disk", text: formatSize(int(disk)), value: int64(disk)},
+		{key: "binlogBytes", title: "Binary log volume", text: formatSize(int(binlog)), value: int64(binlog)},
+		{key: "totalDiskBytes", title: "Disk needed (data + binlog)", text: formatSize(int(disk + binlog)), value: int64(disk + binlog)},
+		{key: "rowsPerSecond", title: "Throughput", text: throughputText, value: throughputValue},
+		{key: "durationSeconds", title: "Duration", text: durationText, value: durationValue},
+	})
+	return nil
+}
+
+// calibrate measures the insert throughput of the server in rows per second by
+// inserting into temporary tables for a short time. Temporary tables are dropped
+// with their connection, so nothing is left behind. The throughput of more than
+// calibrationMaxWorkers workers is extrapolated linearly.
+func (opt *GeneratorOptions) calibrate() (float64, error) {
+	client, err := opt.getClient("")
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	if err := client.Ping(); err != nil {
+		return 0, err
+	}
+
+	// temporary tables need an existing database
+	database := "mysql"
+	var exists int
+	err = client.QueryRow("SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE schema_name = ?", opt.dbName).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists > 0 {
+		database = opt.dbName
+	}
+
+	workers := opt.concurrency
+	if workers > calibrationMaxWorkers {
+		workers = calibrationMaxWorkers
+	}
+	out.info("Calibrating the throughput with %d connection(s) for %s.....", workers, calibrationDuration.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), calibrationDuration)
+	defer cancel()
+	var inserted int64
+	var firstErr error
+	var once sync.Once
+	wg := sync.WaitGroup{}
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			if err := calibrateWorker(ctx, client, database, worker, &inserted); err != nil {
+				once.Do(func() { firstErr = err })
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	rowsPerSecond := float64(atomic.LoadInt64(&inserted)) / time.Since(start).Seconds()
+	return rowsPerSecond * float64(opt.concurrency) / float64(workers), nil
+}
+
+// calibrateWorker inserts generated rows into a temporary table of its own connection until the context is done
+func calibrateWorker(ctx context.Context, client *sql.DB, database string, worker int, inserted *int64) error {
+	conn, err := client.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	table := fmt.Sprintf("`%s`._generator_calibration%d", database, worker)
+	if _, err := conn.ExecContext(context.Background(), "CREATE TEMPORARY "+generator.CreateTableStatement(table)[len("CREATE "):]); err != nil {
+		return fmt.Errorf("failed to create calibration table. Reason: %v", err)
+	}
+	defer conn.ExecContext(context.Background(), "DROP TEMPORARY TABLE IF EXISTS "+table)
+
+	r := rand.New(rand.NewSource(int64(worker)))
+	for ctx.Err() == nil {
+		if _, err := conn.ExecContext(context.Background(), generator.InsertStatement(table, generator.NewRow(r))); err != nil {
+			return err
+		}
+		atomic.AddInt64(inserted, 1)
+	}
+	return nil
+}
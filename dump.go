@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// outputFile is a file that is compressed according to its extension: gzip for
+// .gz and zstd for .zst. zstd compression is done by the zstd binary.
+type outputFile struct {
+	*bufio.Writer
+	closers []func() error
+}
+
+func createOutputFile(path string) (*outputFile, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	f := &outputFile{}
+	var w io.Writer
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zst":
+		cmd := exec.Command("zstd", "-q", "-f", "-o", path)
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start zstd for %q. Make sure the zstd binary is installed. Reason: %v", path, err)
+		}
+		w = stdin
+		f.closers = append(f.closers, stdin.Close, cmd.Wait)
+	default:
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w = file
+		f.closers = append(f.closers, file.Close)
+		if strings.EqualFold(filepath.Ext(path), ".gz") {
+			gz := gzip.NewWriter(file)
+			w = gz
+			f.closers = append([]func() error{gz.Close}, f.closers...)
+		}
+	}
+	f.Writer = bufio.NewWriterSize(w, OneMB)
+	return f, nil
+}
+
+// Close flushes the buffered data, finishes the compression and closes the file.
+// Closing a closed file does nothing.
+func (f *outputFile) Close() error {
+	err := f.Flush()
+	for _, fn := range f.closers {
+		if cerr := fn(); err == nil {
+			err = cerr
+		}
+	}
+	f.closers = nil
+	return err
+}
+
+// dumpRows returns the number of rows of each table of the dump. The rows are
+// estimated so that the database is as large as --size once the dump is loaded.
+func (opt *GeneratorOptions) dumpRows() ([]int64, error) {
+	if opt.duration > 0 && !isFlagSet("size") {
+		return nil, fmt.Errorf("the size of the dump must be provided with --size")
+	}
+	desiredAmount, err := opt.parseSize()
+	if err != nil {
+		return nil, err
+	}
+	total := int64(math.Ceil(float64(desiredAmount) / opt.estimateRow().disk))
+	rows := make([]int64, opt.tableNumber)
+	for i := range rows {
+		rows[i] = total / int64(opt.tableNumber)
+		if int64(i) < total%int64(opt.tableNumber) {
+			rows[i]++
+		}
+	}
+	return rows, nil
+}
+
+// dumpSeed returns the seed of the generated rows of a dump
+func (opt *GeneratorOptions) dumpSeed() int64 {
+	if opt.seed != 0 {
+		return opt.seed
+	}
+	return time.Now().UnixNano()
+}
+
+// offlineOptions returns the options of the generation of a dump with a single
+// worker, so that the rows are the same as the rows inserted by the generate
+// command with the same --seed, --txn-rows and --multi-table-txn-ratio and a
+// --concurrency of 1. The number of rows is estimated from --size.
+func (opt *GeneratorOptions) offlineOptions() (generator.Options, error) {
+	opt.parseMultiTableTxn()
+	rows, err := opt.dumpRows()
+	if err != nil {
+		return generator.Options{}, err
+	}
+	var total int64
+	for _, count := range rows {
+		total += count
+	}
+	return generator.Options{
+		Database:        opt.dbName,
+		Tables:          opt.tableNumber,
+		Rows:            total,
+		TxnRows:         opt.txnRows,
+		MultiTableRatio: opt.multiTableTxnRatio,
+		Overwrite:       opt.overwrite,
+		Seed:            opt.dumpSeed(),
+		DiscardIDs:      true,
+	}, nil
+}
+
+// generateOffline writes the rows of a dump to the sink
+func (opt *GeneratorOptions) generateOffline(sink generator.Sink, genOpts generator.Options, verb string) (*generator.Result, error) {
+	gen, err := generator.NewWithSink(sink, genOpts)
+	if err != nil {
+		return nil, err
+	}
+	result, err := gen.Generate(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < genOpts.Tables; i++ {
+		table := generator.TableName(i)
+		out.info("%s %d rows of table %q", verb, result.Tables[table], table)
+	}
+	return result, nil
+}
+
+// writeDump writes the statements that would have been executed to --output-file
+// in the layout of mysqldump, without connecting to the server
+func (opt *GeneratorOptions) writeDump() error {
+	genOpts, err := opt.offlineOptions()
+	if err != nil {
+		return err
+	}
+	f, err := createOutputFile(opt.outputFile)
+	if err != nil {
+		return err
+	}
+
+	startingTime := time.Now()
+	out.phase("dumping", "Writing dump of database %q to %q.....", opt.dbName, opt.outputFile)
+	sink := generator.NewSQLSink(f)
+	result, err := opt.generateOffline(sink, genOpts, "Dumped")
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := sink.Close(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write dump %q. Reason: %v", opt.outputFile, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write dump %q. Reason: %v", opt.outputFile, err)
+	}
+
+	info, err := os.Stat(opt.outputFile)
+	if err != nil {
+		return err
+	}
+	out.text("\n=========================== Summery ===========================\n")
+	out.summary([]summaryField{
+		{key: "rowsInserted", title: "Total rows dumped", text: strconv.FormatInt(result.Rows, 10), value: result.Rows},
+		{key: "fileBytes", title: "Dump file size", text: formatSize(int(info.Size())), value: info.Size()},
+		{key: "durationSeconds", title: "Total time taken", text: time.Since(startingTime).String(), value: time.Since(startingTime).Seconds()},
+		{key: "seed", title: "Seed", text: strconv.FormatInt(result.Seed, 10), value: result.Seed},
+	})
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers that are handled specially.
+// ref: https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	ErrDiskFull          = 1021 // ER_DISK_FULL
+	ErrDBAccessDenied    = 1044 // ER_DBACCESS_DENIED_ERROR
+	ErrAccessDenied      = 1045 // ER_ACCESS_DENIED_ERROR
+	ErrBadDB             = 1049 // ER_BAD_DB_ERROR
+	ErrDuplicateKey      = 1062 // ER_DUP_ENTRY
+	ErrRecordFileFull    = 1114 // ER_RECORD_FILE_FULL
+	ErrTableAccessDenied = 1142 // ER_TABLEACCESS_DENIED_ERROR
+	ErrNoSuchTable       = 1146 // ER_NO_SUCH_TABLE
+	ErrLockWaitTimeout   = 1205 // ER_LOCK_WAIT_TIMEOUT
+	ErrLockDeadlock      = 1213 // ER_LOCK_DEADLOCK
+	ErrOptionPreventsRun = 1290 // ER_OPTION_PREVENTS_STATEMENT (i.e. --read-only)
+	ErrReadOnlyMode      = 1836 // ER_READ_ONLY_MODE
+	ErrServerGone        = 2006 // CR_SERVER_GONE_ERROR
+	ErrServerLost        = 2013 // CR_SERVER_LOST
+
+	// maximum delay between two retries of a statement
+	maxRetryBackoff = 10 * time.Second
+)
+
+// errorClass describes how a failed statement should be handled
+type errorClass struct {
+	name string
+	// retryable errors are retried with backoff before being counted as a failure
+	retryable bool
+	// fatal errors abort the whole run immediately
+	fatal bool
+	// failover errors are expected while the primary is being switched over.
+	// they are retried as long as the outage is within the --outage-tolerance.
+	failover bool
+}
+
+var (
+	classDeadlock        = errorClass{name: "deadlock", retryable: true}
+	classLockWaitTimeout = errorClass{name: "lock_wait_timeout", retryable: true}
+	classConnectionLost  = errorClass{name: "connection_lost", retryable: true, failover: true}
+	classReadOnly        = errorClass{name: "read_only", fatal: true, failover: true}
+	classDiskFull        = errorClass{name: "disk_full", fatal: true}
+	classAccessDenied    = errorClass{name: "access_denied", fatal: true}
+	classMissingSchema   = errorClass{name: "missing_schema", fatal: true}
+	classDuplicateKey    = errorClass{name: "duplicate_key"}
+	classOther           = errorClass{name: "other"}
+)
+
+// classifyError classifies the error of a failed statement by its MySQL error number
+func classifyError(err error) errorClass {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return classConnectionLost
+	}
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return classOther
+	}
+	switch mysqlErr.Number {
+	case ErrLockDeadlock:
+		return classDeadlock
+	case ErrLockWaitTimeout:
+		return classLockWaitTimeout
+	case ErrServerGone, ErrServerLost:
+		return classConnectionLost
+	case ErrOptionPreventsRun, ErrReadOnlyMode:
+		return classReadOnly
+	case ErrDiskFull, ErrRecordFileFull:
+		return classDiskFull
+	case ErrDBAccessDenied, ErrAccessDenied, ErrTableAccessDenied:
+		return classAccessDenied
+	case ErrBadDB, ErrNoSuchTable:
+		return classMissingSchema
+	case ErrDuplicateKey:
+		return classDuplicateKey
+	default:
+		return classOther
+	}
+}
+
+// errorBudget counts the failed statements of all the workers and aborts the run
+// when a fatal error occurs or the number of failures exceeds the budget.
+type errorBudget struct {
+	max    int64
+	count  int64
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+func newErrorBudget(max int, cancel context.CancelFunc) *errorBudget {
+	return &errorBudget{max: int64(max), cancel: cancel}
+}
+
+// add counts a failed statement. It returns a non-nil error if the run has to be aborted.
+func (b *errorBudget) add(err error) error {
+	count := atomic.AddInt64(&b.count, 1)
+	var outageErr *outageExceededError
+	if errors.As(err, &outageErr) {
+		return fmt.Errorf("aborting due to fatal error: %w", err)
+	}
+	if class := classifyError(err); class.fatal {
+		return fmt.Errorf("aborting due to fatal error (%s): %w", class.name, err)
+	}
+	if b.max > 0 && count > b.max {
+		return fmt.Errorf("aborting as the number of failed statements exceeded the error budget of %d. Last error: %w", b.max, err)
+	}
+	return nil
+}
+
+// abort stops all the workers. Only the first error is kept.
+func (b *errorBudget) abort(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return
+	}
+	b.err = err
+	out.error(err, "Aborting the run")
+	b.cancel()
+}
+
+// failure returns the error that aborted the run, if any
+func (b *errorBudget) failure() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// failed returns the number of failed statements
+func (b *errorBudget) failed() int64 {
+	return atomic.LoadInt64(&b.count)
+}
+
+// execWithRetry runs fn and retries it with exponential backoff as long as it
+// fails with a retryable error and the retry limit has not been reached. For
+// writes, the failover errors are retried until the outage exceeds the
+// --outage-tolerance, if provided.
+func (opt *GeneratorOptions) execWithRetry(ctx context.Context, write bool, fn func() error) error {
+	backoff := opt.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			if write {
+				outages.writeSucceeded()
+			}
+			return nil
+		}
+
+		class := classifyError(err)
+		if class.failover {
+			if write {
+				outages.writeFailed(err)
+			}
+			opt.handleFailoverError()
+		}
+		if write && class.failover && opt.outageTolerance > 0 {
+			if outages.elapsed() >= opt.outageTolerance {
+				return &outageExceededError{tolerance: opt.outageTolerance, err: err}
+			}
+		} else if attempt >= opt.maxRetries || !class.retryable {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// failureBackoff waits before a failed batch is written again. The delay is
+// doubled with the consecutive failures of the worker, so that an error that
+// persists does not flood the server and the log until the error budget runs out.
+func (opt *GeneratorOptions) failureBackoff(ctx context.Context, failures int) {
+	backoff := opt.retryBackoff
+	for i := 1; i < failures && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
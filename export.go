@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// export formats
+const (
+	ExportCSV = "csv"
+	ExportTSV = "tsv"
+
+	exportSchemaFile = "schema.sql"
+	exportLoadFile   = "load.sql"
+)
+
+// delimitedFormat is the layout of the exported files
+type delimitedFormat struct {
+	generator.Format
+	header bool
+}
+
+// delimitedFormat returns the layout of --export-format adjusted by the --csv-* flags
+func (opt *GeneratorOptions) delimitedFormat() (delimitedFormat, error) {
+	var f delimitedFormat
+	switch opt.exportFormat {
+	case ExportCSV:
+		f = delimitedFormat{Format: generator.CSV}
+	case ExportTSV:
+		f = delimitedFormat{Format: generator.TSV}
+	default:
+		return f, fmt.Errorf("unknown export format %q. Expected one of (csv, tsv, shell)", opt.exportFormat)
+	}
+	if isFlagSet("csv-delimiter") {
+		f.Delimiter = unescapeOption(opt.csvDelimiter)
+	}
+	if isFlagSet("csv-quote") {
+		f.Quote = unescapeOption(opt.csvQuote)
+	}
+	if isFlagSet("csv-null") {
+		f.Null = opt.csvNull
+	}
+	if isFlagSet("csv-line-terminator") {
+		f.LineTerminator = unescapeOption(opt.csvLineTerminator)
+	}
+	f.header = opt.csvHeader
+
+	switch {
+	case f.Delimiter == "" || f.LineTerminator == "":
+		return f, fmt.Errorf("the delimiter and the line terminator can not be empty")
+	case len(f.Delimiter) > 1:
+		return f, fmt.Errorf("the delimiter must be a single character")
+	case len(f.Quote) > 1:
+		return f, fmt.Errorf("the quote must be a single character")
+	}
+	return f, nil
+}
+
+// unescapeOption replaces the escape sequences of a flag value (i.e. \t) with the characters
+func unescapeOption(value string) string {
+	return strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\r`, "\r", `\\`, `\`).Replace(value)
+}
+
+// loadDataStatement returns the LOAD DATA statement that loads the exported file into the table
+func (f delimitedFormat) loadDataStatement(database, table, file string) string {
+	statement := fmt.Sprintf("LOAD DATA LOCAL INFILE %s INTO TABLE %s.%s CHARACTER SET utf8mb4 FIELDS TERMINATED BY %s",
+		generator.QuoteString(file), generator.QuoteIdentifier(database), generator.QuoteIdentifier(table), generator.QuoteString(f.Delimiter))
+	if f.Quote != "" {
+		statement += " OPTIONALLY ENCLOSED BY " + generator.QuoteString(f.Quote)
+	}
+	statement += ` ESCAPED BY '\\' LINES TERMINATED BY ` + generator.QuoteString(f.LineTerminator)
+	if f.header {
+		statement += " IGNORE 1 LINES"
+	}
+	return statement + " (" + strings.Join(generator.Columns, ",") + ")"
+}
+
+// writeExport writes one delimited file per table along with the schema and the
+// LOAD DATA statements to --export-dir, without connecting to the server. The
+// rows are the same as the rows of a SQL dump with the same seed.
+func (opt *GeneratorOptions) writeExport() error {
+	if opt.exportDir == "" {
+		return fmt.Errorf("--export-format requires --export-dir")
+	}
+	format, err := opt.delimitedFormat()
+	if err != nil {
+		return err
+	}
+	genOpts, err := opt.offlineOptions()
+	if err != nil {
+		return err
+	}
+
+	startingTime := time.Now()
+	out.phase("exporting", "Exporting database %q to %q.....", opt.dbName, opt.exportDir)
+	schema, err := createOutputFile(filepath.Join(opt.exportDir, exportSchemaFile))
+	if err != nil {
+		return err
+	}
+	if opt.overwrite {
+		fmt.Fprintf(schema, "DROP DATABASE IF EXISTS %s;\n", generator.QuoteIdentifier(opt.dbName))
+	}
+	fmt.Fprintf(schema, "CREATE DATABASE IF NOT EXISTS %s;\nUSE %s;\n", generator.QuoteIdentifier(opt.dbName), generator.QuoteIdentifier(opt.dbName))
+	load, err := createOutputFile(filepath.Join(opt.exportDir, exportLoadFile))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < genOpts.Tables; i++ {
+		table := generator.TableName(i)
+		fmt.Fprintf(schema, "\n%s;\n", generator.DumpTableStatement(table))
+		// mysqlimport loads a file into the table named after the file
+		file := fmt.Sprintf("%s.%s", table, opt.exportFormat)
+		fmt.Fprintf(load, "%s;\n", format.loadDataStatement(opt.dbName, table, file))
+	}
+	sink := generator.NewCSVSink(opt.exportDir)
+	sink.Format, sink.Header, sink.Extension = format.Format, format.header, opt.exportFormat
+	result, err := opt.generateOffline(sink, genOpts, "Exported")
+	if cerr := sink.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to export database %q. Reason: %v", opt.dbName, cerr)
+	}
+	if err != nil {
+		schema.Close()
+		load.Close()
+		return err
+	}
+	for _, f := range []*outputFile{schema, load} {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	out.text("\n=========================== Summery ===========================\n")
+	out.summary([]summaryField{
+		{key: "rowsInserted", title: "Total rows exported", text: strconv.FormatInt(result.Rows, 10), value: result.Rows},
+		{key: "durationSeconds", title: "Total time taken", text: time.Since(startingTime).String(), value: time.Since(startingTime).Seconds()},
+		{key: "seed", title: "Seed", text: strconv.FormatInt(result.Seed, 10), value: result.Seed},
+	})
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// outageWindow is a period during which no write succeeded. It starts with the
+// first failed write and ends with the first successful write after that.
+type outageWindow struct {
+	start time.Time
+	end   time.Time
+	// reason is the error of the first failed write of the window
+	reason string
+}
+
+func (w outageWindow) duration() time.Duration {
+	return w.end.Sub(w.start)
+}
+
+// outageTracker tracks the write outages caused by failovers
+type outageTracker struct {
+	mu      sync.Mutex
+	current *outageWindow
+	windows []outageWindow
+
+	// last time the endpoint has been checked for read-only mode
+	lastCheck time.Time
+}
+
+var outages = &outageTracker{}
+
+// writeFailed marks the beginning of an outage if there is no ongoing outage
+func (t *outageTracker) writeFailed(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current != nil {
+		return
+	}
+	t.current = &outageWindow{start: time.Now(), reason: err.Error()}
+	if out.json {
+		out.emit(EventOutage, event{"state": "started", "start": t.current.start.UTC().Format(time.RFC3339Nano), "error": err.Error(), "code": errorCode(err)})
+		return
+	}
+	out.printf("Write outage started. Reason: %v.\n", err)
+}
+
+// writeSucceeded marks the end of the ongoing outage, if any
+func (t *outageTracker) writeSucceeded() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current == nil {
+		return
+	}
+	t.current.end = time.Now()
+	t.windows = append(t.windows, *t.current)
+	window := *t.current
+	t.current = nil
+	if out.json {
+		out.emit(EventOutage, event{
+			"state":           "ended",
+			"start":           window.start.UTC().Format(time.RFC3339Nano),
+			"end":             window.end.UTC().Format(time.RFC3339Nano),
+			"durationSeconds": window.duration().Seconds(),
+		})
+		return
+	}
+	out.printf("Write outage ended after %s.\n", window.duration().String())
+}
+
+// elapsed returns for how long the ongoing outage has been lasting
+func (t *outageTracker) elapsed() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current == nil {
+		return 0
+	}
+	return time.Since(t.current.start)
+}
+
+// observed returns true if any write outage has been observed
+func (t *outageTracker) observed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current != nil || len(t.windows) > 0
+}
+
+// report prints the outage windows observed during the run. An outage that is
+// still ongoing is reported as ended at the time of the report.
+func (t *outageTracker) report() {
+	t.mu.Lock()
+	windows := append([]outageWindow(nil), t.windows...)
+	if t.current != nil {
+		w := *t.current
+		w.end = time.Now()
+		windows = append(windows, w)
+	}
+	t.mu.Unlock()
+
+	var total, longest time.Duration
+	for _, w := range windows {
+		total += w.duration()
+		if w.duration() > longest {
+			longest = w.duration()
+		}
+	}
+
+	if out.json {
+		list := make([]event, 0, len(windows))
+		for _, w := range windows {
+			list = append(list, event{
+				"start":           w.start.UTC().Format(time.RFC3339Nano),
+				"end":             w.end.UTC().Format(time.RFC3339Nano),
+				"durationSeconds": w.duration().Seconds(),
+				"reason":          w.reason,
+			})
+		}
+		out.emit(EventOutages, event{"count": len(windows), "totalSeconds": total.Seconds(), "longestSeconds": longest.Seconds(), "windows": list})
+		return
+	}
+
+	out.printf("\n========================= Write Outages =======================\n")
+	if len(windows) == 0 {
+		out.printf("No write outage has been observed\n")
+		return
+	}
+	for i, w := range windows {
+		out.printf("%35s: %s - %s (%s)\n", fmt.Sprintf("Outage %d", i+1), w.start.Format("15:04:05.000"), w.end.Format("15:04:05.000"), w.duration().String())
+	}
+	out.printf("%35s: %s\n", "Total outage", total.String())
+	out.printf("%35s: %s\n", "Longest outage", longest.String())
+}
+
+// handleFailoverError is called when a write fails due to a lost connection or a
+// read-only endpoint. It checks whether the endpoint is now read-only (i.e. the
+// primary has been demoted) and drops the idle connections so that the new
+// connections are routed to the new primary by the service.
+func (opt *GeneratorOptions) handleFailoverError() {
+	outages.mu.Lock()
+	if time.Since(outages.lastCheck) < time.Second {
+		outages.mu.Unlock()
+		return
+	}
+	outages.lastCheck = time.Now()
+	outages.mu.Unlock()
+
+	// drop the idle connections. the connections that are in use will be dropped
+	// by the workers using them when they fail.
+	maxConnection := opt.maxConnections()
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(maxConnection)
+
+	var superReadOnly, readOnly int
+	err := db.QueryRow("SELECT @@global.super_read_only, @@global.read_only").Scan(&superReadOnly, &readOnly)
+	if err != nil {
+		// the endpoint is still unreachable
+		return
+	}
+	if superReadOnly == 1 || readOnly == 1 {
+		out.info("Endpoint %s:%d is read-only (super_read_only=%d, read_only=%d). Waiting for a writable primary.....", opt.host, opt.port, superReadOnly, readOnly)
+	}
+}
+
+// outageExceededError is returned when a write outage lasts longer than the tolerance
+type outageExceededError struct {
+	tolerance time.Duration
+	err       error
+}
+
+func (e *outageExceededError) Error() string {
+	return fmt.Sprintf("write outage exceeded the tolerance of %s. Last error: %v", e.tolerance.String(), e.err)
+}
+
+func (e *outageExceededError) Unwrap() error {
+	return e.err
+}
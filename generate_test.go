@@ -0,0 +1,534 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"github.com/hossainemruz/mysql-data-generator/internal/fakemysql"
+)
+
+// defaultOptions holds the flag defaults. It is captured in TestMain as the flags
+// are registered after the package variables have been initialized.
+var defaultOptions GeneratorOptions
+
+func TestMain(m *testing.M) {
+	defaultOptions = opt
+	out.w = ioutil.Discard
+	os.Exit(m.Run())
+}
+
+// newTestServer resets the options to the flag defaults and connects the
+// generator to a new in-process stand-in of the server
+func newTestServer(t *testing.T) *fakemysql.Server {
+	t.Helper()
+	server := fakemysql.New()
+	opt = defaultOptions
+	opt.reportInterval = 0
+	opt.retryBackoff = time.Millisecond
+	limiter = nil
+	writeLedger = nil
+
+	open := openDB
+	openDB = func(cfg *mysql.Config) (*sql.DB, error) {
+		return server.DB(cfg.DBName), nil
+	}
+	t.Cleanup(func() {
+		openDB = open
+	})
+	return server
+}
+
+// rowCount returns the number of rows in all the tables of the database
+func rowCount(server *fakemysql.Server, database string) int {
+	count := 0
+	for _, table := range server.Tables(database) {
+		count += len(server.Rows(database, table))
+	}
+	return count
+}
+
+func TestGenerateCreatesSchema(t *testing.T) {
+	server := newTestServer(t)
+	opt.dbName = "schemaTest"
+	opt.tableNumber = 3
+	opt.size = "16KB"
+	opt.rate = "200rows/s"
+
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	if got := server.Tables("schemaTest"); len(got) != 3 || got[0] != "table0" || got[2] != "table2" {
+		t.Errorf("created tables %v, want table0 to table2", got)
+	}
+	if rowCount(server, "schemaTest") == 0 {
+		t.Error("no rows have been inserted")
+	}
+}
+
+func TestGenerateStopsAtSize(t *testing.T) {
+	server := newTestServer(t)
+	opt.size = "32KB"
+	opt.concurrency = 2
+	opt.rate = "500rows/s"
+
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	if size := server.Size(opt.dbName); size < 32*OneKB {
+		t.Errorf("stopped at %d bytes, want at least %d", size, 32*OneKB)
+	}
+	for _, row := range server.Rows(opt.dbName, "table0") {
+		if row["name"] == "" || row["height"] == nil {
+			t.Fatalf("inserted an incomplete row %v", row)
+		}
+	}
+}
+
+func TestGenerateStopsAtDuration(t *testing.T) {
+	server := newTestServer(t)
+	opt.duration = 500 * time.Millisecond
+	opt.txnRows = 5
+	opt.rate = "100rows/s"
+
+	start := time.Now()
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < opt.duration {
+		t.Errorf("stopped after %s, want at least %s", elapsed, opt.duration)
+	}
+	if count := rowCount(server, opt.dbName); count == 0 || count%5 != 0 {
+		t.Errorf("inserted %d rows, want whole transactions of 5 rows", count)
+	}
+}
+
+func TestGenerateAppendsToExistingDatabase(t *testing.T) {
+	server := newTestServer(t)
+	client := server.DB("")
+	defer client.Close()
+	existing := generator.Row{Name: "Existing Row"}
+	for _, statement := range []string{"CREATE DATABASE sampleData", "USE sampleData", generator.CreateTableStatement("table0"), generator.InsertStatement("table0", existing)} {
+		if _, err := client.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opt.size = "8KB"
+	opt.rate = "200rows/s"
+
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	rows := server.Rows("sampleData", "table0")
+	if len(rows) < 2 || rows[0]["name"] != existing.Name {
+		t.Errorf("the existing row has not been kept: %d rows, first %v", len(rows), rows[0])
+	}
+}
+
+func TestGenerateOverwrite(t *testing.T) {
+	server := newTestServer(t)
+	client := server.DB("")
+	defer client.Close()
+	for _, statement := range []string{"CREATE DATABASE sampleData", "USE sampleData", "CREATE TABLE old (id int)", "INSERT INTO old VALUES (1)"} {
+		if _, err := client.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opt.overwrite = true
+	opt.size = "8KB"
+	opt.rate = "200rows/s"
+
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	if got := server.Tables("sampleData"); len(got) != 1 || got[0] != "table0" {
+		t.Errorf("the database has tables %v after overwriting, want only table0", got)
+	}
+	dropped := false
+	for _, statement := range server.Statements() {
+		if statement == "DROP DATABASE IF EXISTS sampleData" {
+			dropped = true
+		}
+	}
+	if !dropped {
+		t.Error("the database has not been dropped")
+	}
+}
+
+func TestGeneratePingFailure(t *testing.T) {
+	server := newTestServer(t)
+	refused := errors.New("dial tcp 127.0.0.1:3306: connect: connection refused")
+	server.Fail("PING", -1, refused)
+
+	if err := opt.generateData(); !errors.Is(err, refused) {
+		t.Fatalf("generateData() = %v, want %v", err, refused)
+	}
+	if len(server.Databases()) != 0 {
+		t.Errorf("created databases %v after the failed ping", server.Databases())
+	}
+}
+
+func TestGenerateCreateTableFailure(t *testing.T) {
+	server := newTestServer(t)
+	server.Fail("CREATE TABLE", -1, &mysql.MySQLError{Number: 1142, Message: "CREATE command denied to user 'app'@'localhost' for table 'table0'"})
+
+	err := opt.generateData()
+	if err == nil || !strings.Contains(err.Error(), "CREATE command denied") {
+		t.Fatalf("generateData() = %v, want the CREATE TABLE error", err)
+	}
+}
+
+func TestGenerateAbortsOnFatalError(t *testing.T) {
+	server := newTestServer(t)
+	diskFull := &mysql.MySQLError{Number: ErrDiskFull, Message: "Disk full (/var/lib/mysql); waiting for someone to free some space..."}
+	server.Fail("INSERT", -1, diskFull)
+	opt.concurrency = 3
+
+	start := time.Now()
+	if err := opt.generateData(); !errors.Is(err, diskFull) {
+		t.Fatalf("generateData() = %v, want %v", err, diskFull)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("aborting took %s", elapsed)
+	}
+	if count := rowCount(server, opt.dbName); count != 0 {
+		t.Errorf("inserted %d rows, want 0", count)
+	}
+}
+
+func TestGenerateAbortsOnReadOnlyServer(t *testing.T) {
+	server := newTestServer(t)
+	// the server turns read-only after the schema has been created, i.e. on a failover
+	readOnly := &mysql.MySQLError{Number: ErrOptionPreventsRun, Message: "The MySQL server is running with the --super-read-only option so it cannot execute this statement"}
+	server.Fail("INSERT", -1, readOnly)
+
+	if err := opt.generateData(); !errors.Is(err, readOnly) {
+		t.Fatalf("generateData() = %v, want %v", err, readOnly)
+	}
+	var superReadOnlyChecked bool
+	for _, statement := range server.Statements() {
+		if strings.Contains(statement, "@@global.super_read_only") {
+			superReadOnlyChecked = true
+		}
+	}
+	if !superReadOnlyChecked {
+		t.Error("the read-only state of the server has not been checked")
+	}
+}
+
+func TestGenerateExceedsErrorBudget(t *testing.T) {
+	server := newTestServer(t)
+	tooLong := &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name' at row 1"}
+	server.Fail("INSERT", -1, tooLong)
+	opt.maxErrors = 3
+
+	err := opt.generateData()
+	if !errors.Is(err, tooLong) || !strings.Contains(err.Error(), "error budget of 3") {
+		t.Fatalf("generateData() = %v, want the error budget to be exceeded", err)
+	}
+}
+
+func TestGenerateAbortsOnAccessDenied(t *testing.T) {
+	server := newTestServer(t)
+	denied := &mysql.MySQLError{Number: ErrTableAccessDenied, Message: "INSERT command denied to user 'app'@'localhost' for table 'table0'"}
+	server.Fail("INSERT", -1, denied)
+
+	// the default error budget is unlimited
+	if err := opt.generateData(); !errors.Is(err, denied) || !strings.Contains(err.Error(), "access_denied") {
+		t.Fatalf("generateData() = %v, want %v", err, denied)
+	}
+}
+
+func TestGenerateBacksOffOnFailedBatches(t *testing.T) {
+	server := newTestServer(t)
+	tooLong := &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name' at row 1"}
+	server.Fail("INSERT", -1, tooLong)
+	opt.duration = 500 * time.Millisecond
+	opt.rate = "1000rows/s"
+
+	// the default error budget is unlimited, so the run only stops at the deadline
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	inserts := 0
+	for _, statement := range server.Statements() {
+		if strings.HasPrefix(statement, "INSERT") {
+			inserts++
+		}
+	}
+	// 1ms doubled on every failure
+	if inserts == 0 || inserts > 20 {
+		t.Errorf("the failed batch has been written %d times in 500ms, want it to back off", inserts)
+	}
+}
+
+func TestGenerateRetriesDeadlocks(t *testing.T) {
+	server := newTestServer(t)
+	server.Fail("INSERT", 2, &mysql.MySQLError{Number: ErrLockDeadlock, Message: "Deadlock found when trying to get lock"})
+	opt.size = "8KB"
+	opt.maxErrors = 1
+	opt.rate = "200rows/s"
+
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed after the deadlocks: %v", err)
+	}
+	if rowCount(server, opt.dbName) == 0 {
+		t.Error("no rows have been inserted after the deadlocks")
+	}
+}
+
+func TestGenerateStopsAtDurationWhenSizeFails(t *testing.T) {
+	server := newTestServer(t)
+	opt.duration = 500 * time.Millisecond
+	opt.rate = "100rows/s"
+	denied := &mysql.MySQLError{Number: 1142, Message: "ANALYZE command denied to user 'app'@'localhost'"}
+	// the initial size is measured, then the size queries fail for the rest of the run
+	time.AfterFunc(200*time.Millisecond, func() {
+		server.Fail("ANALYZE TABLE", -1, denied)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- opt.generateData()
+	}()
+	select {
+	case err := <-done:
+		// the final size of the summary can not be measured either
+		if !errors.Is(err, denied) {
+			t.Fatalf("generateData() = %v, want %v", err, denied)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run did not stop at the deadline while the size could not be measured")
+	}
+}
+
+func TestGenerateMultiTableTransactions(t *testing.T) {
+	server := newTestServer(t)
+	opt.tableNumber = 3
+	opt.txnRows = 3
+	opt.multiTableTxnRatio = 1
+	opt.size = "16KB"
+	opt.rate = "300rows/s"
+
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	// every transaction writes a row to each table
+	for _, table := range server.Tables(opt.dbName) {
+		if got, want := len(server.Rows(opt.dbName, table)), rowCount(server, opt.dbName)/3; got != want {
+			t.Errorf("%s has %d rows, want %d", table, got, want)
+		}
+	}
+
+	opt.txnRows = 1
+	if err := opt.generateData(); err == nil || !strings.Contains(err.Error(), "multi-table") {
+		t.Errorf("generateData() with multi-table transactions of a single row = %v, want an error", err)
+	}
+}
+
+func TestGenerateResumesFromCheckpoint(t *testing.T) {
+	server := newTestServer(t)
+	opt.checkpoint = filepath.Join(t.TempDir(), "checkpoint.json")
+	opt.size = "16KB"
+	opt.rate = "200rows/s"
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	first := rowCount(server, opt.dbName)
+
+	// pretend that the run has been interrupted before reaching a larger size
+	cp, err := opt.loadCheckpoint()
+	if err != nil || cp == nil || !cp.Completed || cp.RowsGenerated != int64(first) {
+		t.Fatalf("checkpoint = %+v, %v, want the completed run with %d rows", cp, err, first)
+	}
+	cp.Completed = false
+	data, _ := json.Marshal(cp)
+	if err := ioutil.WriteFile(opt.checkpoint, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	opt.resume = true
+	opt.concurrency = 2
+	if err := opt.generateData(); err == nil || !strings.Contains(err.Error(), "--concurrency=1") {
+		t.Fatalf("generateData() resumed with another concurrency = %v, want an error", err)
+	}
+	opt.concurrency = 1
+	desired := server.Size(opt.dbName) + 16*OneKB
+	opt.size = fmt.Sprintf("%dKB", desired/OneKB)
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed to resume: %v", err)
+	}
+
+	cp, err = opt.loadCheckpoint()
+	if err != nil || !cp.Completed {
+		t.Fatalf("checkpoint = %+v, %v, want a completed run", cp, err)
+	}
+	if count := rowCount(server, opt.dbName); count <= first || cp.RowsGenerated != int64(count) || cp.Seed == 0 {
+		t.Errorf("the resumed run has %d rows in total and the checkpoint %d, want more than %d", count, cp.RowsGenerated, first)
+	}
+	if size := server.Size(opt.dbName); size < desired/OneKB*OneKB {
+		t.Errorf("the resumed run stopped at %d bytes, want at least %d from the size before the first run", size, desired/OneKB*OneKB)
+	}
+}
+
+func TestBinlogDeleteOnlyWorkload(t *testing.T) {
+	newTestServer(t)
+	opt.binlogFile = filepath.Join(t.TempDir(), "binlog.000001")
+	opt.workloadMix = "delete:1"
+	opt.size = "64KB"
+	mix, err := parseWorkloadMix(opt.workloadMix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fewer deletes than rows
+	opt.binlogTransactions = 2
+	if err := opt.writeBinlog(mix); err != nil {
+		t.Fatalf("writeBinlog() failed: %v", err)
+	}
+
+	// the tables run out of rows before the deletes have been written
+	opt.binlogTransactions = 1000000
+	done := make(chan error, 1)
+	go func() {
+		done <- opt.writeBinlog(mix)
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "can not change any row") {
+			t.Fatalf("writeBinlog() = %v, want an error once the tables are empty", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("writeBinlog() did not stop once the tables were empty")
+	}
+}
+
+func TestExportMatchesGenerate(t *testing.T) {
+	server := newTestServer(t)
+	opt.seed = 7
+	opt.tableNumber = 2
+	opt.txnRows = 3
+	opt.size = "256KB"
+	opt.rate = "1000rows/s"
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+
+	opt.exportFormat = ExportTSV
+	opt.exportDir = t.TempDir()
+	if err := opt.writeExport(); err != nil {
+		t.Fatalf("writeExport() failed: %v", err)
+	}
+	// both generate the rows of the same worker, until their own target
+	for _, table := range []string{"table0", "table1"} {
+		data, err := ioutil.ReadFile(filepath.Join(opt.exportDir, table+".tsv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		rows := server.Rows(opt.dbName, table)
+		if len(rows) == 0 || len(data) == 0 {
+			t.Fatalf("%s has %d inserted and %d exported rows", table, len(rows), len(lines))
+		}
+		for i := 0; i < len(rows) && i < len(lines); i++ {
+			fields := strings.Split(lines[i], "\t")
+			inserted := fmt.Sprintln(rows[i]["id"], rows[i]["height"], rows[i]["weight"], rows[i]["age"])
+			if exported := fmt.Sprintln(fields[0], fields[2], fields[3], fields[4]); exported != inserted {
+				t.Fatalf("row %d of %s is %q in the export, want %q as inserted", i+1, table, exported, inserted)
+			}
+		}
+	}
+}
+
+func TestOfflineOverwrite(t *testing.T) {
+	newTestServer(t)
+	dir := t.TempDir()
+	opt.overwrite = true
+	opt.size = "32KB"
+	opt.outputFile = filepath.Join(dir, "dump.sql")
+	if err := opt.writeDump(); err != nil {
+		t.Fatalf("writeDump() failed: %v", err)
+	}
+	opt.exportFormat = ExportCSV
+	opt.exportDir = dir
+	if err := opt.writeExport(); err != nil {
+		t.Fatalf("writeExport() failed: %v", err)
+	}
+	for _, file := range []string{"dump.sql", exportSchemaFile} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "DROP DATABASE IF EXISTS `sampleData`") {
+			t.Errorf("%s does not drop the database with --overwrite", file)
+		}
+	}
+
+	opt.outputFile, opt.exportFormat = "", ExportShell
+	if err := opt.generateData(); err == nil || !strings.Contains(err.Error(), "--overwrite") {
+		t.Errorf("generateData() of a MySQL Shell dump with --overwrite = %v, want an error", err)
+	}
+}
+
+func TestGenerateOverwriteTruncatesLedger(t *testing.T) {
+	server := newTestServer(t)
+	opt.ledger = filepath.Join(t.TempDir(), "ledger.log")
+	opt.size = "128KB"
+	opt.rate = "200rows/s"
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed: %v", err)
+	}
+	// the overwritten database has fewer rows, so the entries of the first run would be left over
+	opt.overwrite = true
+	opt.size = "16KB"
+	if err := opt.generateData(); err != nil {
+		t.Fatalf("generateData() failed to overwrite: %v", err)
+	}
+
+	rows, err := readLedger(opt.ledger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledgered := 0
+	for table, ids := range rows {
+		ledgered += len(ids)
+		if existing := len(server.Rows(opt.dbName, table)); existing != len(ids) {
+			t.Errorf("the ledger has %d rows of %s, want the %d rows of the overwritten database", len(ids), table, existing)
+		}
+	}
+	if ledgered == 0 {
+		t.Error("the ledger is empty")
+	}
+}
+
+func TestShellDumpStopsOnChunkError(t *testing.T) {
+	newTestServer(t)
+	dir := t.TempDir()
+	opt.size = "256KB"
+	opt.exportFormat = ExportShell
+	opt.exportCompression = CompressionNone
+	opt.exportDir = dir
+	opt.chunkSize = "8KB"
+	// a directory in place of the first chunk makes it fail to be written
+	if err := os.Mkdir(filepath.Join(dir, "sampleData@table0@0.tsv"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := opt.writeShellDump(); err == nil {
+		t.Fatal("writeShellDump() succeeded while a chunk could not be written")
+	}
+	chunks, err := filepath.Glob(filepath.Join(dir, "*.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 {
+		t.Errorf("writeShellDump() wrote %d more chunks after the first one failed", len(chunks)-1)
+	}
+}
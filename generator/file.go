@@ -0,0 +1,339 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maximum size of a single extended INSERT statement of SQLSink. It is the
+// default net_buffer_length of mysqldump, so the statements load with the
+// default max_allowed_packet of the server.
+const maxInsertSize = 1024 * 1024
+
+// DumpHeader sets the session variables of a dump like mysqldump does
+const DumpHeader = `
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;
+/*!40101 SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION */;
+/*!50503 SET NAMES utf8mb4 */;
+/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;
+/*!40103 SET TIME_ZONE='+00:00' */;
+/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;
+/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;
+/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
+/*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;
+`
+
+// DumpFooter restores the session variables changed by DumpHeader
+const DumpFooter = `
+/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
+
+/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
+/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
+/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;
+/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;
+/*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;
+/*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;
+/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
+`
+
+// SQLSink writes the rows as a dump in the layout of mysqldump, which can be
+// replayed with the mysql client. CreateTables writes the structure of the
+// tables and the rows of each table are packed into extended INSERT statements
+// of up to 1MB, which are written once they are full. The ids count from 1 in
+// every table, so the dump is meant for a new database. Close writes the
+// remaining statements and finishes the dump.
+type SQLSink struct {
+	mu       sync.Mutex
+	w        io.Writer
+	bytes    int64
+	started  bool
+	database string
+	// tables in the order they have been created and the unfinished INSERT
+	// statement of each of them
+	tables  []string
+	ids     map[string]int64
+	inserts map[string]*strings.Builder
+	// section is the table whose data is being written
+	section string
+}
+
+// NewSQLSink returns a sink that writes the dump to w. The dump must be finished with Close.
+func NewSQLSink(w io.Writer) *SQLSink {
+	return &SQLSink{w: w, ids: map[string]int64{}, inserts: map[string]*strings.Builder{}}
+}
+
+// write writes the statements, which must be called with the lock held
+func (s *SQLSink) write(statements string) error {
+	n, err := io.WriteString(s.w, statements)
+	s.bytes += int64(n)
+	return err
+}
+
+// endSection finishes the data of the current table, which must be called with the lock held
+func (s *SQLSink) endSection(b *strings.Builder) {
+	if s.section == "" {
+		return
+	}
+	quoted := QuoteIdentifier(s.section)
+	fmt.Fprintf(b, "/*!40000 ALTER TABLE %s ENABLE KEYS */;\nUNLOCK TABLES;\n", quoted)
+	s.section = ""
+}
+
+// flush finishes the INSERT statement of the table, which must be called with the lock held
+func (s *SQLSink) flush(b *strings.Builder, table string) {
+	insert := s.inserts[table]
+	if insert.Len() == 0 {
+		return
+	}
+	if s.section != table {
+		s.endSection(b)
+		quoted := QuoteIdentifier(table)
+		fmt.Fprintf(b, "\n--\n-- Dumping data for table %s\n--\n\n", quoted)
+		fmt.Fprintf(b, "LOCK TABLES %s WRITE;\n/*!40000 ALTER TABLE %s DISABLE KEYS */;\n", quoted, quoted)
+		s.section = table
+	}
+	b.WriteString(insert.String())
+	b.WriteString(";\n")
+	insert.Reset()
+}
+
+// flushAll finishes the INSERT statements of all the tables, which must be called with the lock held
+func (s *SQLSink) flushAll(b *strings.Builder) {
+	for _, table := range s.tables {
+		s.flush(b, table)
+	}
+	s.endSection(b)
+}
+
+func (s *SQLSink) CreateTables(ctx context.Context, database string, tables []string, overwrite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var b strings.Builder
+	s.flushAll(&b)
+	if !s.started {
+		fmt.Fprintf(&b, "-- mysql-data-generator dump\n--\n-- Host: (offline)    Database: %s\n-- ------------------------------------------------------\n", database)
+		b.WriteString(DumpHeader)
+		s.started = true
+	}
+	if database != s.database {
+		quoted := QuoteIdentifier(database)
+		fmt.Fprintf(&b, "\n--\n-- Current Database: %s\n--\n\n", quoted)
+		if overwrite {
+			fmt.Fprintf(&b, "/*!40000 DROP DATABASE IF EXISTS %s*/;\n\n", quoted)
+		}
+		fmt.Fprintf(&b, "CREATE DATABASE /*!32312 IF NOT EXISTS*/ %s /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\n\n", quoted)
+		fmt.Fprintf(&b, "USE %s;\n", quoted)
+		s.database = database
+		s.tables = nil
+		s.ids = map[string]int64{}
+		s.inserts = map[string]*strings.Builder{}
+	}
+	for _, table := range tables {
+		quoted := QuoteIdentifier(table)
+		fmt.Fprintf(&b, "\n--\n-- Table structure for table %s\n--\n\n", quoted)
+		fmt.Fprintf(&b, "DROP TABLE IF EXISTS %s;\n", quoted)
+		b.WriteString("/*!40101 SET @saved_cs_client     = @@character_set_client */;\n/*!50503 SET character_set_client = utf8mb4 */;\n")
+		fmt.Fprintf(&b, "%s;\n", DumpTableStatement(table))
+		b.WriteString("/*!40101 SET character_set_client = @saved_cs_client */;\n")
+		if _, ok := s.inserts[table]; !ok {
+			s.tables = append(s.tables, table)
+			s.inserts[table] = &strings.Builder{}
+		}
+		s.ids[table] = 0
+	}
+	return s.write(b.String())
+}
+
+func (s *SQLSink) Write(database, table string, rows []Row) error {
+	return s.WriteTables(database, []TableRows{{Table: table, Rows: rows}})
+}
+
+// WriteTables adds the rows to the INSERT statements of their tables
+func (s *SQLSink) WriteTables(database string, batch []TableRows) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range batch {
+		if _, ok := s.inserts[t.Table]; !ok || database != s.database {
+			return fmt.Errorf("table %q of database %q has not been created", t.Table, database)
+		}
+	}
+	var b strings.Builder
+	for _, t := range batch {
+		insert := s.inserts[t.Table]
+		for i := range t.Rows {
+			s.ids[t.Table]++
+			t.Rows[i].ID = s.ids[t.Table]
+			values := SQLValues(t.Rows[i])
+			if insert.Len() > 0 && insert.Len()+len(values)+1 > maxInsertSize {
+				s.flush(&b, t.Table)
+			}
+			if insert.Len() == 0 {
+				fmt.Fprintf(insert, "INSERT INTO %s VALUES %s", QuoteIdentifier(t.Table), values)
+			} else {
+				insert.WriteString(",")
+				insert.WriteString(values)
+			}
+		}
+	}
+	return s.write(b.String())
+}
+
+// Size returns the number of bytes of the dump so far, including the unfinished INSERT statements
+func (s *SQLSink) Size(ctx context.Context, database string, tables []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size := s.bytes
+	for _, insert := range s.inserts {
+		size += int64(insert.Len())
+	}
+	return size, nil
+}
+
+// Close writes the remaining INSERT statements and the end of the dump. It does not close the writer.
+func (s *SQLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return nil
+	}
+	var b strings.Builder
+	s.flushAll(&b)
+	b.WriteString(DumpFooter)
+	fmt.Fprintf(&b, "\n-- Dump completed on %s\n", time.Now().UTC().Format("2006-01-02 15:04:05"))
+	s.started = false
+	return s.write(b.String())
+}
+
+// CSVSink writes the rows of every table of a database to <table>.<Extension>
+// in a directory, which mysqlimport or LOAD DATA can load. The files are
+// truncated when the tables are created and the ids count from 1 in every table.
+type CSVSink struct {
+	// Format of the files. Defaults to CSV, which LOAD DATA reads with FIELDS
+	// TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"'.
+	Format Format
+	// Header writes the names of the columns as the first line of the files
+	Header bool
+	// Extension of the files. Defaults to csv.
+	Extension string
+
+	dir string
+
+	mu sync.Mutex
+	// files are the open files of the tables, which are written through the buffers
+	files   map[string]*os.File
+	buffers map[string]*bufio.Writer
+	ids     map[string]int64
+	bytes   int64
+}
+
+// NewCSVSink returns a sink that writes the files to dir. The files must be closed with Close.
+func NewCSVSink(dir string) *CSVSink {
+	return &CSVSink{
+		Format:    CSV,
+		Extension: "csv",
+		dir:       dir,
+		files:     map[string]*os.File{},
+		buffers:   map[string]*bufio.Writer{},
+		ids:       map[string]int64{},
+	}
+}
+
+func (s *CSVSink) CreateTables(ctx context.Context, database string, tables []string, overwrite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if _, ok := s.files[table]; ok {
+			continue
+		}
+		f, err := os.Create(filepath.Join(s.dir, table+"."+s.Extension))
+		if err != nil {
+			return err
+		}
+		s.files[table] = f
+		s.buffers[table] = bufio.NewWriterSize(f, maxInsertSize)
+		if s.Header {
+			columns := make([]interface{}, len(Columns))
+			for i, column := range Columns {
+				columns[i] = column
+			}
+			var buf bytes.Buffer
+			if err := s.Format.WriteRow(&buf, columns); err != nil {
+				return err
+			}
+			n, err := s.buffers[table].Write(buf.Bytes())
+			s.bytes += int64(n)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *CSVSink) Write(database, table string, rows []Row) error {
+	return s.WriteTables(database, []TableRows{{Table: table, Rows: rows}})
+}
+
+// WriteTables appends the rows to the files of their tables
+func (s *CSVSink) WriteTables(database string, batch []TableRows) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range batch {
+		if _, ok := s.files[t.Table]; !ok {
+			return fmt.Errorf("table %q has not been created", t.Table)
+		}
+	}
+	for _, t := range batch {
+		var buf bytes.Buffer
+		for i := range t.Rows {
+			s.ids[t.Table]++
+			t.Rows[i].ID = s.ids[t.Table]
+			if err := s.Format.WriteRow(&buf, t.Rows[i].Values()); err != nil {
+				return err
+			}
+		}
+		n, err := s.buffers[t.Table].Write(buf.Bytes())
+		s.bytes += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size returns the number of bytes written
+func (s *CSVSink) Size(ctx context.Context, database string, tables []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes, nil
+}
+
+// Close flushes and closes the files
+func (s *CSVSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for table, f := range s.files {
+		if ferr := s.buffers[table].Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(s.files, table)
+		delete(s.buffers, table)
+	}
+	return err
+}
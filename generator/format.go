@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var sqlEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"'", "\\'",
+	"\"", "\\\"",
+	"\x00", "\\0",
+	"\n", "\\n",
+	"\r", "\\r",
+	"\x1a", "\\Z",
+)
+
+// QuoteString returns the string as a MySQL string literal
+func QuoteString(s string) string {
+	return "'" + sqlEscaper.Replace(s) + "'"
+}
+
+// QuoteIdentifier returns the name as a MySQL quoted identifier
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// SQLValues returns the row as the values of an INSERT statement, including the id
+func SQLValues(row Row) string {
+	return fmt.Sprintf("(%d,%s,%d,%d,%d,%s)", row.ID, QuoteString(row.Name), row.Height, row.Weight, row.Age, QuoteString(row.Description))
+}
+
+// Format is the layout of a delimited file. The special characters of the values
+// are escaped with a backslash, which is the default escape character of LOAD
+// DATA and mysqlimport.
+type Format struct {
+	Delimiter string
+	// Quote encloses the string values. They are not enclosed if it is empty.
+	Quote          string
+	Null           string
+	LineTerminator string
+}
+
+var (
+	// CSV is read by LOAD DATA with FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"'
+	CSV = Format{Delimiter: ",", Quote: `"`, Null: `\N`, LineTerminator: "\n"}
+	// TSV is the default format of LOAD DATA
+	TSV = Format{Delimiter: "\t", Null: `\N`, LineTerminator: "\n"}
+)
+
+// escape escapes the backslash and the special characters of the value. The quote
+// is escaped in the enclosed values, the delimiter and the line terminator
+// everywhere else.
+func (f Format) escape(value string, enclosed bool) string {
+	pairs := []string{`\`, `\\`, "\x00", `\0`, "\x1a", `\Z`, "\n", `\n`, "\r", `\r`, "\t", `\t`}
+	specials := []string{f.Delimiter, f.LineTerminator}
+	if enclosed {
+		specials = []string{f.Quote}
+	}
+	for _, special := range specials {
+		if len(special) == 1 && !strings.Contains("\\\x00\x1a\n\r\t", special) {
+			pairs = append(pairs, special, `\`+special)
+		}
+	}
+	return strings.NewReplacer(pairs...).Replace(value)
+}
+
+// WriteRow writes the values as a line. A nil value is written as Null.
+func (f Format) WriteRow(w io.Writer, values []interface{}) error {
+	var b strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			b.WriteString(f.Delimiter)
+		}
+		switch v := value.(type) {
+		case nil:
+			b.WriteString(f.Null)
+		case string:
+			b.WriteString(f.Quote)
+			b.WriteString(f.escape(v, f.Quote != ""))
+			b.WriteString(f.Quote)
+		default:
+			b.WriteString(f.escape(fmt.Sprint(v), false))
+		}
+	}
+	b.WriteString(f.LineTerminator)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQuoteString(t *testing.T) {
+	tests := map[string]string{
+		"plain":           `'plain'`,
+		"it's":            `'it\'s'`,
+		`say "hi"`:        `'say \"hi\"'`,
+		`back\slash`:      `'back\\slash'`,
+		"line\nbreak\r":   `'line\nbreak\r'`,
+		"nul\x00ctrl\x1a": `'nul\0ctrl\Z'`,
+	}
+	for value, want := range tests {
+		if got := QuoteString(value); got != want {
+			t.Errorf("QuoteString(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	if got, want := QuoteIdentifier("table0"), "`table0`"; got != want {
+		t.Errorf("QuoteIdentifier() = %s, want %s", got, want)
+	}
+	if got, want := QuoteIdentifier("odd`name"), "`odd``name`"; got != want {
+		t.Errorf("QuoteIdentifier() = %s, want %s", got, want)
+	}
+}
+
+func TestSQLValues(t *testing.T) {
+	row := Row{ID: 7, Name: "Brave Lion", Height: 150, Weight: 60, Age: 30, Description: "it's"}
+	if got, want := SQLValues(row), `(7,'Brave Lion',150,60,30,'it\'s')`; got != want {
+		t.Errorf("SQLValues() = %s, want %s", got, want)
+	}
+}
+
+func TestFormatWriteRow(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		values []interface{}
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: CSV,
+			values: []interface{}{int64(1), "Brave Lion", 150, nil},
+			want:   "1,\"Brave Lion\",150,\\N\n",
+		},
+		{
+			name:   "csv escapes the quote and the backslash in enclosed values",
+			format: CSV,
+			values: []interface{}{`a "b", c\d`},
+			want:   `"a \"b\", c\\d"` + "\n",
+		},
+		{
+			name:   "csv escapes the control characters",
+			format: CSV,
+			values: []interface{}{"line\nbreak\ttab\x00"},
+			want:   `"line\nbreak\ttab\0"` + "\n",
+		},
+		{
+			name:   "tsv escapes the delimiter and the line terminator",
+			format: TSV,
+			values: []interface{}{"a\tb\nc", 2},
+			want:   "a\\tb\\nc\t2\n",
+		},
+		{
+			name:   "custom delimiter without quotes",
+			format: Format{Delimiter: "|", Null: "NULL", LineTerminator: "\r\n"},
+			values: []interface{}{"a|b", nil},
+			want:   "a\\|b|NULL\r\n",
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := test.format.WriteRow(&buf, test.values); err != nil {
+			t.Errorf("%s: WriteRow() failed: %v", test.name, err)
+			continue
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: WriteRow() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
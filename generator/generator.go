@@ -0,0 +1,527 @@
+// Package generator inserts random data into a MySQL database. It is the engine
+// of the mysql-data-generator command and can be embedded in Go programs, i.e.
+// to seed and verify the databases of integration tests:
+//
+//	gen, err := generator.New(db, generator.Options{Database: "e2e", Rows: 1000})
+//	...
+//	result, err := gen.Generate(ctx)
+//	...
+//	err = gen.Verify(ctx)
+//
+// The rows are written through a Sink, so the same generation can write to a
+// SQL or CSV file, or to memory in unit tests, with NewWithSink.
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultDatabase is the database of the generated tables when none is given
+	DefaultDatabase = "sampleData"
+	// DefaultProgressInterval is the interval of the progress callback and of the size checks
+	DefaultProgressInterval = time.Second
+
+	// number of ids checked by a single query of Verify
+	verifyBatchSize = 1000
+)
+
+// Options configures a Generator. Generation stops as soon as any of Rows, Size
+// or Duration is reached, so at least one of them is required.
+type Options struct {
+	// Database of the generated tables. It is created if it does not exist.
+	Database string
+	// Tables is the number of generated tables (table0, table1, ...). Defaults to 1.
+	Tables int
+	// Rows is the number of rows to insert
+	Rows int64
+	// Size is the amount of data to add to the database in bytes, as measured by
+	// the sink, which must implement Measurer. MySQLSink measures it from the
+	// table statistics of the server like the command does.
+	Size int64
+	// Duration is the time to insert data for
+	Duration time.Duration
+	// Concurrency is the number of parallel workers. Defaults to 1.
+	Concurrency int
+	// TxnRows is the number of rows written by each batch, i.e. by each
+	// transaction of MySQLSink. The rows are written one by one if it is 0.
+	TxnRows int
+	// MultiTableRatio is the fraction of the batches (0 to 1) that are written to
+	// several tables as a whole: the first row is a parent row in a random table
+	// and the other rows are its children, written in turn to the other tables.
+	// The tables have no foreign keys, so the children do not reference the
+	// parent. It requires a TxnRows of at least 2 and a sink that implements
+	// MultiTableWriter. The other batches are written to a single random table.
+	MultiTableRatio float64
+	// Overwrite drops the database before generating the data
+	Overwrite bool
+	// Seed of the generated rows. Random if 0. With a Concurrency of 1, runs with
+	// the same seed and Rows generate the same rows. Each worker generates its
+	// own sequence of batches, so with more workers the rows depend on how many
+	// batches each worker gets to write before the generation stops.
+	Seed int64
+	// OnProgress is called every ProgressInterval and once more when the
+	// generation is over. It is always called from the same goroutine.
+	OnProgress func(Progress)
+	// ProgressInterval defaults to DefaultProgressInterval
+	ProgressInterval time.Duration
+
+	// Resume holds the number of batches written by each worker of an interrupted
+	// generation with the same Seed. The workers continue with their next batch,
+	// so that they write the rows the interrupted generation would have written.
+	Resume []int64
+	// DiscardIDs does not keep the ids of the written rows, which Verify needs,
+	// to save memory on long generations
+	DiscardIDs bool
+	// Throttle is called before every batch is written, i.e. to limit the rate of
+	// the writes. The generation is aborted with the error it returns, unless the
+	// generation has been stopped already.
+	Throttle func(ctx context.Context, batch Batch) error
+	// Retry is called to write every batch with the function that writes it to
+	// the sink, i.e. to retry the writes that fail with a transient error. The
+	// context is cancelled when the generation stops.
+	Retry func(ctx context.Context, write func() error) error
+	// OnBatch is called after every batch has been written. It is called by the
+	// workers concurrently.
+	OnBatch func(Batch)
+	// OnError is called with every batch that could not be written. The worker
+	// writes the same batch again if it returns nil, otherwise the generation is
+	// aborted with the returned error. Without it, the first failure aborts the
+	// generation. It is called by the workers concurrently.
+	OnError func(batch Batch, err error) error
+}
+
+// Batch is a batch of rows written by a worker as a whole
+type Batch struct {
+	// Worker is the index of the worker, from 0 to Concurrency-1
+	Worker int
+	// Tables holds the rows in the order they are written. The ids of the rows
+	// are set once the batch has been written.
+	Tables []TableRows
+	// Rows is the number of rows of the batch
+	Rows int64
+	// Bytes is the size of the values of the rows
+	Bytes int64
+	// Latency is the time taken to write the batch, including the retries
+	Latency time.Duration
+}
+
+// names returns the names of the tables of the batch for the error messages
+func (b Batch) names() string {
+	if len(b.Tables) == 1 {
+		return fmt.Sprintf("table %q", b.Tables[0].Table)
+	}
+	names := make([]string, len(b.Tables))
+	for i, t := range b.Tables {
+		names[i] = fmt.Sprintf("%q", t.Table)
+	}
+	return "tables " + strings.Join(names, ", ")
+}
+
+// Progress is the progress of a generation
+type Progress struct {
+	// Rows is the number of rows inserted so far
+	Rows int64
+	// Bytes is the size of the values of the rows inserted so far
+	Bytes int64
+	// DatabaseSize is the size of the database as measured by the sink. It is
+	// only measured when Options.Size is set.
+	DatabaseSize int64
+	// SizeErr is the error of measuring DatabaseSize, if any
+	SizeErr error
+	// Elapsed is the time since the generation has started
+	Elapsed time.Duration
+	// Done is true for the last call of a generation
+	Done bool
+}
+
+// Result is the outcome of a generation
+type Result struct {
+	// Rows is the number of rows inserted
+	Rows int64
+	// Tables is the number of rows inserted into each table
+	Tables map[string]int64
+	// Seed is the seed of the generated rows
+	Seed     int64
+	Duration time.Duration
+}
+
+// MissingRowsError is returned by Verify when rows inserted by Generate do not exist in the database
+type MissingRowsError struct {
+	// Missing are the ids of the missing rows of each table
+	Missing map[string][]int64
+}
+
+func (e *MissingRowsError) Error() string {
+	tables := make([]string, 0, len(e.Missing))
+	total := 0
+	for table, ids := range e.Missing {
+		tables = append(tables, fmt.Sprintf("%s: %d", table, len(ids)))
+		total += len(ids)
+	}
+	sort.Strings(tables)
+	return fmt.Sprintf("%d inserted row(s) are missing (%s)", total, strings.Join(tables, ", "))
+}
+
+// Generator inserts random rows into the tables of a database through a sink.
+// It remembers the ids of the inserted rows, so that Verify can confirm that they
+// still exist later (i.e. after a failover or a restore).
+type Generator struct {
+	sink Sink
+	opts Options
+
+	mu  sync.Mutex
+	ids map[string][]int64
+}
+
+// New returns a Generator that inserts the rows with the client. The client is not closed by the generator.
+func New(db *sql.DB, opts Options) (*Generator, error) {
+	if db == nil {
+		return nil, errors.New("a database client is required")
+	}
+	return NewWithSink(NewMySQLSink(db), opts)
+}
+
+// NewWithSink returns a Generator that writes the rows to the sink
+func NewWithSink(sink Sink, opts Options) (*Generator, error) {
+	if sink == nil {
+		return nil, errors.New("a sink is required")
+	}
+	if opts.Database == "" {
+		opts.Database = DefaultDatabase
+	}
+	if opts.Tables == 0 {
+		opts.Tables = 1
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = 1
+	}
+	if opts.ProgressInterval == 0 {
+		opts.ProgressInterval = DefaultProgressInterval
+	}
+	switch {
+	case opts.Tables < 0 || opts.Concurrency < 0 || opts.TxnRows < 0:
+		return nil, errors.New("the number of tables, the concurrency and the rows per transaction can not be negative")
+	case opts.Rows < 0 || opts.Size < 0 || opts.Duration < 0:
+		return nil, errors.New("the rows, the size and the duration can not be negative")
+	case opts.Rows == 0 && opts.Size == 0 && opts.Duration == 0:
+		return nil, errors.New("one of the rows, the size or the duration is required")
+	case opts.MultiTableRatio < 0 || opts.MultiTableRatio > 1:
+		return nil, errors.New("the multi-table ratio must be between 0 and 1")
+	case opts.MultiTableRatio > 0 && opts.TxnRows < 2:
+		return nil, errors.New("multi-table batches require at least 2 rows per transaction")
+	}
+	if _, ok := sink.(Measurer); opts.Size > 0 && !ok {
+		return nil, errors.New("the size requires a sink that can measure the written data")
+	}
+	if _, ok := sink.(MultiTableWriter); opts.MultiTableRatio > 0 && !ok {
+		return nil, errors.New("multi-table batches require a sink that can write several tables as a whole")
+	}
+	return &Generator{sink: sink, opts: opts, ids: map[string][]int64{}}, nil
+}
+
+// tables returns the names of the tables
+func (g *Generator) tables() []string {
+	tables := make([]string, g.opts.Tables)
+	for i := range tables {
+		tables[i] = TableName(i)
+	}
+	return tables
+}
+
+// DatabaseSize returns the size of the data of the tables as measured by the sink
+func (g *Generator) DatabaseSize(ctx context.Context) (int64, error) {
+	m, ok := g.sink.(Measurer)
+	if !ok {
+		return 0, errors.New("the sink can not measure the written data")
+	}
+	return m.Size(ctx, g.opts.Database, g.tables())
+}
+
+// generation is the shared state of the workers of a generation
+type generation struct {
+	seed      int64
+	rows      int64
+	bytes     int64
+	remaining int64
+	tables    []int64
+}
+
+// Generate creates the database and the tables if needed and inserts random rows
+// until Rows, Size or Duration is reached. It returns the error of the first
+// failed write, or the error of the context if it has been cancelled. The
+// result holds the rows inserted until then in both cases.
+func (g *Generator) Generate(ctx context.Context) (*Result, error) {
+	start := time.Now()
+	if err := g.sink.CreateTables(ctx, g.opts.Database, g.tables(), g.opts.Overwrite); err != nil {
+		return nil, err
+	}
+	var initialSize int64
+	if g.opts.Size > 0 {
+		var err error
+		if initialSize, err = g.DatabaseSize(ctx); err != nil {
+			return nil, fmt.Errorf("failed to measure the size of database %q. Reason: %w", g.opts.Database, err)
+		}
+	}
+
+	state := &generation{seed: g.opts.Seed, remaining: g.opts.Rows, tables: make([]int64, g.opts.Tables)}
+	if state.seed == 0 {
+		state.seed = time.Now().UnixNano()
+	}
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var firstErr error
+	var once sync.Once
+	wg := sync.WaitGroup{}
+	for i := 0; i < g.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			if err := g.insertRows(runCtx, worker, state); err != nil {
+				once.Do(func() { firstErr = err })
+				stop()
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	progress := func(last bool) Progress {
+		p := Progress{
+			Rows:    atomic.LoadInt64(&state.rows),
+			Bytes:   atomic.LoadInt64(&state.bytes),
+			Elapsed: time.Since(start),
+			Done:    last,
+		}
+		if g.opts.Size > 0 {
+			p.DatabaseSize, p.SizeErr = g.DatabaseSize(context.Background())
+		}
+		return p
+	}
+	ticker := time.NewTicker(g.opts.ProgressInterval)
+	defer ticker.Stop()
+	var deadline <-chan time.Time
+	if g.opts.Duration > 0 {
+		timer := time.NewTimer(g.opts.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-deadline:
+			stop()
+		case <-ticker.C:
+			if g.opts.OnProgress == nil && g.opts.Size == 0 {
+				continue
+			}
+			p := progress(false)
+			if g.opts.Size > 0 && p.SizeErr == nil && p.DatabaseSize-initialSize >= g.opts.Size {
+				stop()
+			}
+			if g.opts.OnProgress != nil {
+				g.opts.OnProgress(p)
+			}
+		}
+	}
+	if g.opts.OnProgress != nil {
+		g.opts.OnProgress(progress(true))
+	}
+
+	result := &Result{Rows: state.rows, Tables: map[string]int64{}, Seed: state.seed, Duration: time.Since(start)}
+	for i, rows := range state.tables {
+		result.Tables[TableName(i)] = rows
+	}
+	if firstErr != nil {
+		return result, firstErr
+	}
+	return result, ctx.Err()
+}
+
+// claim reserves up to n of the remaining rows. It returns 0 once all the rows have been claimed.
+func (g *Generator) claim(state *generation, n int64) int64 {
+	if g.opts.Rows == 0 {
+		return n
+	}
+	for {
+		remaining := atomic.LoadInt64(&state.remaining)
+		if remaining <= 0 {
+			return 0
+		}
+		if remaining < n {
+			n = remaining
+		}
+		if atomic.CompareAndSwapInt64(&state.remaining, remaining, remaining-n) {
+			return n
+		}
+	}
+}
+
+// refund returns the rows of a failed batch to the remaining rows
+func (g *Generator) refund(state *generation, n int64) {
+	if g.opts.Rows > 0 {
+		atomic.AddInt64(&state.remaining, n)
+	}
+}
+
+// insertRows inserts batches of rows until the context is cancelled or all the rows have been inserted
+func (g *Generator) insertRows(ctx context.Context, worker int, state *generation) error {
+	size := int64(g.opts.TxnRows)
+	if size < 1 {
+		size = 1
+	}
+	var number int64
+	if worker < len(g.opts.Resume) {
+		number = g.opts.Resume[worker]
+	}
+	r := rand.New(rand.NewSource(1))
+	for ctx.Err() == nil {
+		n := g.claim(state, size)
+		if n == 0 {
+			return nil
+		}
+		// a failed batch is generated again from the same seed, so the batch
+		// number only advances with the written batches
+		r.Seed(BatchSeed(state.seed, worker, number))
+		batch, tables := g.newBatch(r, worker, n)
+		if g.opts.Throttle != nil {
+			if err := g.opts.Throttle(ctx, batch); err != nil {
+				g.refund(state, n)
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+
+		start := time.Now()
+		err := g.write(ctx, batch)
+		batch.Latency = time.Since(start)
+		if err != nil {
+			g.refund(state, n)
+			err = fmt.Errorf("failed to insert %d row(s) into %s. Reason: %w", n, batch.names(), err)
+			if g.opts.OnError == nil {
+				return err
+			}
+			if err := g.opts.OnError(batch, err); err != nil {
+				return err
+			}
+			continue
+		}
+		number++
+
+		atomic.AddInt64(&state.rows, n)
+		atomic.AddInt64(&state.bytes, batch.Bytes)
+		for i, t := range batch.Tables {
+			atomic.AddInt64(&state.tables[tables[i]], int64(len(t.Rows)))
+		}
+		if !g.opts.DiscardIDs {
+			g.mu.Lock()
+			for _, t := range batch.Tables {
+				for _, row := range t.Rows {
+					g.ids[t.Table] = append(g.ids[t.Table], row.ID)
+				}
+			}
+			g.mu.Unlock()
+		}
+		if g.opts.OnBatch != nil {
+			g.opts.OnBatch(batch)
+		}
+	}
+	return nil
+}
+
+// newBatch generates the next batch of n rows of the worker. It returns the
+// batch and the indexes of its tables.
+func (g *Generator) newBatch(r *rand.Rand, worker int, n int64) (Batch, []int) {
+	first := r.Int() % g.opts.Tables
+	multiTable := g.opts.MultiTableRatio >= 1 || (g.opts.MultiTableRatio > 0 && r.Float64() < g.opts.MultiTableRatio)
+	batch := Batch{Worker: worker, Rows: n}
+	var tables []int
+	for i := 0; i < int(n); i++ {
+		table := first
+		if multiTable && i > 0 && g.opts.Tables > 1 {
+			table = (first + 1 + (i-1)%(g.opts.Tables-1)) % g.opts.Tables
+		}
+		if len(tables) == 0 || tables[len(tables)-1] != table {
+			tables = append(tables, table)
+			batch.Tables = append(batch.Tables, TableRows{Table: TableName(table)})
+		}
+		row := NewRow(r)
+		batch.Bytes += rowSize(row)
+		last := &batch.Tables[len(batch.Tables)-1]
+		last.Rows = append(last.Rows, row)
+	}
+	return batch, tables
+}
+
+// write writes the batch to the sink, through Options.Retry if provided
+func (g *Generator) write(ctx context.Context, batch Batch) error {
+	write := func() error {
+		if len(batch.Tables) == 1 {
+			return g.sink.Write(g.opts.Database, batch.Tables[0].Table, batch.Tables[0].Rows)
+		}
+		return g.sink.(MultiTableWriter).WriteTables(g.opts.Database, batch.Tables)
+	}
+	if g.opts.Retry != nil {
+		return g.opts.Retry(ctx, write)
+	}
+	return write()
+}
+
+// Verify confirms that every row inserted by Generate exists in the database.
+// It returns a *MissingRowsError if some of the rows are missing. The sink must
+// implement Checker and the ids must not have been discarded.
+func (g *Generator) Verify(ctx context.Context) error {
+	checker, ok := g.sink.(Checker)
+	if !ok {
+		return errors.New("the sink can not read the rows back")
+	}
+	if g.opts.DiscardIDs {
+		return errors.New("the ids of the written rows have been discarded")
+	}
+	g.mu.Lock()
+	tables := make(map[string][]int64, len(g.ids))
+	for table, ids := range g.ids {
+		tables[table] = append([]int64(nil), ids...)
+	}
+	g.mu.Unlock()
+
+	missing := map[string][]int64{}
+	for table, ids := range tables {
+		for len(ids) > 0 {
+			n := len(ids)
+			if n > verifyBatchSize {
+				n = verifyBatchSize
+			}
+			found, err := checker.Existing(ctx, g.opts.Database, table, ids[:n])
+			if err != nil {
+				return fmt.Errorf("failed to verify the rows of table %q. Reason: %w", table, err)
+			}
+			for _, id := range ids[:n] {
+				if !found[id] {
+					missing[table] = append(missing[table], id)
+				}
+			}
+			ids = ids[n:]
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingRowsError{Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewValidatesOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		sink Sink
+		opts Options
+	}{
+		{name: "no sink", opts: Options{Rows: 1}},
+		{name: "no stop condition", sink: NewMemorySink(), opts: Options{}},
+		{name: "negative rows", sink: NewMemorySink(), opts: Options{Rows: -1}},
+		{name: "negative tables", sink: NewMemorySink(), opts: Options{Rows: 1, Tables: -1}},
+		{name: "negative concurrency", sink: NewMemorySink(), opts: Options{Rows: 1, Concurrency: -1}},
+		{name: "size without measurer", sink: struct{ Sink }{NewMemorySink()}, opts: Options{Size: 1024}},
+		{name: "multi-table ratio above 1", sink: NewMemorySink(), opts: Options{Rows: 1, TxnRows: 2, MultiTableRatio: 1.5}},
+		{name: "multi-table batches of a single row", sink: NewMemorySink(), opts: Options{Rows: 1, TxnRows: 1, MultiTableRatio: 1}},
+		{name: "multi-table batches without writer", sink: struct{ Sink }{NewMemorySink()}, opts: Options{Rows: 1, TxnRows: 2, MultiTableRatio: 1}},
+	}
+	for _, test := range tests {
+		if _, err := NewWithSink(test.sink, test.opts); err == nil {
+			t.Errorf("%s: NewWithSink() succeeded, want an error", test.name)
+		}
+	}
+	if _, err := New(nil, Options{Rows: 1}); err == nil {
+		t.Error("New() without a client succeeded, want an error")
+	}
+}
+
+func TestGenerateRows(t *testing.T) {
+	sink := NewMemorySink()
+	gen, err := NewWithSink(sink, Options{Tables: 3, Rows: 103, Concurrency: 4, TxnRows: 5, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gen.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if result.Rows != 103 {
+		t.Errorf("inserted %d rows, want 103", result.Rows)
+	}
+	if result.Seed != 1 {
+		t.Errorf("seed is %d, want 1", result.Seed)
+	}
+
+	tables := sink.Tables(DefaultDatabase)
+	if len(tables) != 3 {
+		t.Fatalf("created tables %v, want 3 tables", tables)
+	}
+	var total int64
+	for _, table := range tables {
+		rows := sink.Rows(DefaultDatabase, table)
+		if int64(len(rows)) != result.Tables[table] {
+			t.Errorf("table %s has %d rows, the result reports %d", table, len(rows), result.Tables[table])
+		}
+		for i, row := range rows {
+			if row.ID != int64(i+1) {
+				t.Errorf("row %d of table %s has id %d, want %d", i, table, row.ID, i+1)
+			}
+		}
+		total += int64(len(rows))
+	}
+	if total != 103 {
+		t.Errorf("the tables have %d rows, want 103", total)
+	}
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	generate := func() *MemorySink {
+		sink := NewMemorySink()
+		gen, err := NewWithSink(sink, Options{Tables: 2, Rows: 50, TxnRows: 3, Seed: 42})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gen.Generate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return sink
+	}
+	a, b := generate(), generate()
+	for _, table := range []string{"table0", "table1"} {
+		x, y := a.Rows(DefaultDatabase, table), b.Rows(DefaultDatabase, table)
+		if len(x) != len(y) {
+			t.Fatalf("table %s has %d and %d rows with the same seed", table, len(x), len(y))
+		}
+		for i := range x {
+			if x[i] != y[i] {
+				t.Fatalf("row %d of table %s differs with the same seed: %+v != %+v", i, table, x[i], y[i])
+			}
+		}
+	}
+}
+
+func TestGenerateOverwrite(t *testing.T) {
+	sink := NewMemorySink()
+	generate := func(rows int64, overwrite bool) {
+		gen, err := NewWithSink(sink, Options{Database: "db", Rows: rows, Overwrite: overwrite})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gen.Generate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generate(10, false)
+	generate(5, false)
+	if got := len(sink.Rows("db", "table0")); got != 15 {
+		t.Errorf("table0 has %d rows after appending, want 15", got)
+	}
+	generate(4, true)
+	rows := sink.Rows("db", "table0")
+	if len(rows) != 4 {
+		t.Errorf("table0 has %d rows after overwriting, want 4", len(rows))
+	}
+	if rows[0].ID != 1 {
+		t.Errorf("the ids start from %d after overwriting, want 1", rows[0].ID)
+	}
+}
+
+func TestGenerateStopsAtSize(t *testing.T) {
+	sink := NewMemorySink()
+	gen, err := NewWithSink(sink, Options{Size: 100 * 1024, ProgressInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gen.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	size, _ := sink.Size(context.Background(), DefaultDatabase, []string{"table0"})
+	if size < 100*1024 {
+		t.Errorf("stopped at %d bytes, want at least %d", size, 100*1024)
+	}
+	if result.Rows != int64(len(sink.Rows(DefaultDatabase, "table0"))) {
+		t.Errorf("the result reports %d rows, the table has %d", result.Rows, len(sink.Rows(DefaultDatabase, "table0")))
+	}
+}
+
+func TestGenerateStopsAtDuration(t *testing.T) {
+	gen, err := NewWithSink(NewMemorySink(), Options{Duration: 50 * time.Millisecond, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gen.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if result.Duration < 50*time.Millisecond {
+		t.Errorf("stopped after %s, want at least 50ms", result.Duration)
+	}
+	if result.Rows == 0 {
+		t.Error("no rows have been inserted")
+	}
+}
+
+func TestGenerateCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	gen, err := NewWithSink(NewMemorySink(), Options{
+		Rows:             1 << 40,
+		ProgressInterval: time.Millisecond,
+		OnProgress: func(p Progress) {
+			if p.Rows > 0 {
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gen.Generate(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Generate() = %v, want %v", err, context.Canceled)
+	}
+	if result == nil || result.Rows == 0 {
+		t.Errorf("the result of the cancelled generation is %+v, want the inserted rows", result)
+	}
+}
+
+func TestGenerateWriteError(t *testing.T) {
+	errWrite := errors.New("disk full")
+	sink := NewMemorySink()
+	sink.Fail(errWrite)
+	gen, err := NewWithSink(sink, Options{Rows: 10, Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gen.Generate(context.Background())
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("Generate() = %v, want %v", err, errWrite)
+	}
+	if result.Rows != 0 {
+		t.Errorf("inserted %d rows, want 0", result.Rows)
+	}
+}
+
+func TestGenerateProgress(t *testing.T) {
+	var mu sync.Mutex
+	var calls []Progress
+	gen, err := NewWithSink(NewMemorySink(), Options{
+		Duration:         30 * time.Millisecond,
+		ProgressInterval: 5 * time.Millisecond,
+		OnProgress: func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, p)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gen.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) < 2 {
+		t.Fatalf("OnProgress has been called %d times, want at least 2", len(calls))
+	}
+	for i := 1; i < len(calls); i++ {
+		if calls[i].Rows < calls[i-1].Rows || calls[i].Elapsed < calls[i-1].Elapsed {
+			t.Errorf("progress %d went backwards: %+v after %+v", i, calls[i], calls[i-1])
+		}
+		if calls[i-1].Done {
+			t.Errorf("progress %d is done before the last one", i-1)
+		}
+	}
+	last := calls[len(calls)-1]
+	if !last.Done || last.Rows != result.Rows {
+		t.Errorf("the last progress is %+v, want done with %d rows", last, result.Rows)
+	}
+	if last.Bytes == 0 {
+		t.Error("the last progress has no bytes")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	sink := NewMemorySink()
+	gen, err := NewWithSink(sink, Options{Tables: 2, Rows: 20, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.Verify(context.Background()); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+
+	sink.Delete(DefaultDatabase, "table1", 2, 3)
+	err = gen.Verify(context.Background())
+	var missing *MissingRowsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("Verify() = %v, want a *MissingRowsError", err)
+	}
+	if len(missing.Missing) != 1 || len(missing.Missing["table1"]) != 2 {
+		t.Errorf("missing rows are %v, want ids 2 and 3 of table1", missing.Missing)
+	}
+}
+
+func TestVerifyRequiresChecker(t *testing.T) {
+	gen, err := NewWithSink(NewSQLSink(&bytes.Buffer{}), Options{Rows: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.Verify(context.Background()); err == nil {
+		t.Error("Verify() of a sink that can not read the rows back succeeded, want an error")
+	}
+}
+
+func TestGenerateMultiTable(t *testing.T) {
+	var mu sync.Mutex
+	var batches []Batch
+	gen, err := NewWithSink(NewMemorySink(), Options{
+		Tables:          3,
+		Rows:            1000,
+		TxnRows:         5,
+		MultiTableRatio: 0.5,
+		Seed:            1,
+		OnBatch: func(batch Batch) {
+			mu.Lock()
+			defer mu.Unlock()
+			batches = append(batches, batch)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	multiTable := 0
+	for _, batch := range batches {
+		if len(batch.Tables) == 1 {
+			continue
+		}
+		multiTable++
+		// the parent is followed by one child in each of the other tables in turn
+		parent := batch.Tables[0]
+		if len(parent.Rows) != 1 || len(batch.Tables) != 5 {
+			t.Fatalf("multi-table batch %+v, want a parent row and 4 child rows", batch.Tables)
+		}
+		for i, child := range batch.Tables[1:] {
+			if child.Table == parent.Table {
+				t.Errorf("child %d is written to the table of the parent %s", i, parent.Table)
+			}
+		}
+		if batch.Tables[1].Table == batch.Tables[2].Table || batch.Tables[1].Table != batch.Tables[3].Table {
+			t.Errorf("the children are written to %v, want the other tables in turn", batch.Tables[1:])
+		}
+	}
+	if multiTable < 70 || multiTable > 130 {
+		t.Errorf("%d of %d batches are multi-table, want about half", multiTable, len(batches))
+	}
+}
+
+func TestGenerateResume(t *testing.T) {
+	generate := func(sink *MemorySink, rows int64, resume []int64) {
+		gen, err := NewWithSink(sink, Options{Tables: 2, Rows: rows, TxnRows: 2, Seed: 7, Resume: resume})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gen.Generate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uninterrupted, resumed := NewMemorySink(), NewMemorySink()
+	generate(uninterrupted, 40, nil)
+	generate(resumed, 16, nil)
+	// the first 16 rows have been written in 8 batches
+	generate(resumed, 24, []int64{8})
+
+	for _, table := range []string{"table0", "table1"} {
+		x, y := uninterrupted.Rows(DefaultDatabase, table), resumed.Rows(DefaultDatabase, table)
+		if len(x) != len(y) {
+			t.Fatalf("table %s has %d rows after resuming, want %d", table, len(y), len(x))
+		}
+		for i := range x {
+			if x[i] != y[i] {
+				t.Fatalf("row %d of table %s differs after resuming: %+v != %+v", i, table, y[i], x[i])
+			}
+		}
+	}
+}
+
+func TestGenerateOnError(t *testing.T) {
+	sink := NewMemorySink()
+	errWrite := errors.New("lock wait timeout")
+	sink.Fail(errWrite)
+	failures := 0
+	gen, err := NewWithSink(sink, Options{
+		Rows: 10,
+		OnError: func(batch Batch, err error) error {
+			if !errors.Is(err, errWrite) || batch.Rows != 1 {
+				t.Errorf("OnError() called with %+v, %v", batch, err)
+			}
+			if failures++; failures == 3 {
+				sink.Fail(nil)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gen.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() = %v, want the failed batches to be written again", err)
+	}
+	if result.Rows != 10 || len(sink.Rows(DefaultDatabase, "table0")) != 10 {
+		t.Errorf("inserted %d rows, want 10", result.Rows)
+	}
+
+	budget := errors.New("error budget exceeded")
+	sink.Fail(errWrite)
+	gen, err = NewWithSink(sink, Options{
+		Rows: 10,
+		OnError: func(batch Batch, err error) error {
+			return budget
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(context.Background()); err != budget {
+		t.Errorf("Generate() = %v, want the error of OnError %v", err, budget)
+	}
+}
+
+func TestGenerateThrottleAndRetry(t *testing.T) {
+	sink := NewMemorySink()
+	var throttled, attempts int64
+	gen, err := NewWithSink(sink, Options{
+		Rows:       6,
+		TxnRows:    3,
+		DiscardIDs: true,
+		Throttle: func(ctx context.Context, batch Batch) error {
+			throttled += batch.Rows
+			return nil
+		},
+		Retry: func(ctx context.Context, write func() error) error {
+			attempts++
+			return write()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if throttled != 6 || attempts != 2 {
+		t.Errorf("throttled %d rows in %d writes, want 6 rows in 2 writes", throttled, attempts)
+	}
+	if err := gen.Verify(context.Background()); err == nil {
+		t.Error("Verify() after discarding the ids succeeded, want an error")
+	}
+}
@@ -0,0 +1,163 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemorySink keeps the written rows in memory. It is meant for unit tests, which
+// can inspect the rows, delete some of them to simulate a data loss or make the
+// writes fail.
+type MemorySink struct {
+	mu     sync.Mutex
+	tables map[memoryKey]*memoryTable
+	err    error
+}
+
+type memoryKey struct {
+	database string
+	table    string
+}
+
+type memoryTable struct {
+	rows []Row
+	next int64
+}
+
+// NewMemorySink returns an empty sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{tables: map[memoryKey]*memoryTable{}}
+}
+
+func (s *MemorySink) CreateTables(ctx context.Context, database string, tables []string, overwrite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if overwrite {
+		for key := range s.tables {
+			if key.database == database {
+				delete(s.tables, key)
+			}
+		}
+	}
+	for _, table := range tables {
+		if _, ok := s.tables[memoryKey{database, table}]; !ok {
+			s.tables[memoryKey{database, table}] = &memoryTable{next: 1}
+		}
+	}
+	return nil
+}
+
+func (s *MemorySink) Write(database, table string, rows []Row) error {
+	return s.WriteTables(database, []TableRows{{Table: table, Rows: rows}})
+}
+
+func (s *MemorySink) WriteTables(database string, batch []TableRows) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	for _, rows := range batch {
+		if _, ok := s.tables[memoryKey{database, rows.Table}]; !ok {
+			return fmt.Errorf("table '%s.%s' doesn't exist", database, rows.Table)
+		}
+	}
+	for _, rows := range batch {
+		t := s.tables[memoryKey{database, rows.Table}]
+		for i := range rows.Rows {
+			rows.Rows[i].ID = t.next
+			t.next++
+		}
+		t.rows = append(t.rows, rows.Rows...)
+	}
+	return nil
+}
+
+// Size returns the size of the values of the rows
+func (s *MemorySink) Size(ctx context.Context, database string, tables []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var size int64
+	for _, table := range tables {
+		if t, ok := s.tables[memoryKey{database, table}]; ok {
+			for _, row := range t.rows {
+				size += rowSize(row)
+			}
+		}
+	}
+	return size, nil
+}
+
+func (s *MemorySink) Existing(ctx context.Context, database, table string, ids []int64) (map[int64]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tables[memoryKey{database, table}]
+	if !ok {
+		return nil, fmt.Errorf("table '%s.%s' doesn't exist", database, table)
+	}
+	exists := make(map[int64]bool, len(t.rows))
+	for _, row := range t.rows {
+		exists[row.ID] = true
+	}
+	found := map[int64]bool{}
+	for _, id := range ids {
+		if exists[id] {
+			found[id] = true
+		}
+	}
+	return found, nil
+}
+
+// Tables returns the names of the tables of the database
+func (s *MemorySink) Tables(database string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tables []string
+	for key := range s.tables {
+		if key.database == database {
+			tables = append(tables, key.table)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// Rows returns the rows of a table in the order they have been written
+func (s *MemorySink) Rows(database, table string) []Row {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tables[memoryKey{database, table}]; ok {
+		return append([]Row(nil), t.rows...)
+	}
+	return nil
+}
+
+// Delete deletes the rows with the ids from a table
+func (s *MemorySink) Delete(database, table string, ids ...int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tables[memoryKey{database, table}]
+	if !ok {
+		return
+	}
+	deleted := map[int64]bool{}
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	rows := t.rows[:0]
+	for _, row := range t.rows {
+		if !deleted[row.ID] {
+			rows = append(rows, row)
+		}
+	}
+	t.rows = rows
+}
+
+// Fail makes the following writes fail with the error. A nil error makes them succeed again.
+func (s *MemorySink) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
@@ -0,0 +1,140 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Columns are the columns of the generated tables in the order of Row.Values
+var Columns = []string{"id", "name", "height", "weight", "age", "description"}
+
+// Row is a row of the generated tables. The ID is assigned by the server unless
+// the row is written to a file.
+type Row struct {
+	ID          int64
+	Name        string
+	Height      int
+	Weight      int
+	Age         int
+	Description string
+}
+
+// NewRow returns a random row. The same source generates the same rows.
+func NewRow(r *rand.Rand) Row {
+	return Row{
+		Name:        Name(r),
+		Height:      120 + r.Int()%81,
+		Weight:      30 + r.Int()%201,
+		Age:         10 + r.Int()%101,
+		Description: LoremIpsum,
+	}
+}
+
+// Name returns a random name
+func Name(r *rand.Rand) string {
+	return fmt.Sprintf("%s %s", strings.Title(adjectives[r.Int()%totalAdjectives]), strings.Title(nouns[r.Int()%totalNouns]))
+}
+
+// MeanNameLength returns the expected length of the names
+func MeanNameLength() float64 {
+	var adjective, noun float64
+	for _, a := range adjectives {
+		adjective += float64(len(a))
+	}
+	for _, n := range nouns {
+		noun += float64(len(n))
+	}
+	return adjective/float64(len(adjectives)) + 1 + noun/float64(len(nouns))
+}
+
+// Values returns the values of the row in the order of Columns
+func (row Row) Values() []interface{} {
+	return []interface{}{row.ID, row.Name, row.Height, row.Weight, row.Age, row.Description}
+}
+
+// rowSize returns the size of the values of a row
+func rowSize(row Row) int64 {
+	return int64(4*4 + len(row.Name) + len(row.Description))
+}
+
+// Set sets a column of the row other than the id to the value
+func (row *Row) Set(column string, value interface{}) {
+	switch column {
+	case "name":
+		row.Name = value.(string)
+	case "height":
+		row.Height = value.(int)
+	case "weight":
+		row.Weight = value.(int)
+	case "age":
+		row.Age = value.(int)
+	case "description":
+		row.Description = value.(string)
+	}
+}
+
+// TableName returns the name of the i-th generated table
+func TableName(i int) string {
+	return fmt.Sprintf("table%d", i)
+}
+
+// CreateTableStatement returns the statement that creates a generated table
+func CreateTableStatement(table string) string {
+	return fmt.Sprintf("CREATE TABLE %s (id int NOT NULL AUTO_INCREMENT PRIMARY KEY,name text, height int, weight int, age int,description Text)", table)
+}
+
+// DumpTableStatement returns the CREATE TABLE statement of a generated table as mysqldump formats it
+func DumpTableStatement(table string) string {
+	return fmt.Sprintf("CREATE TABLE %s (\n"+
+		"  `id` int NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` text,\n"+
+		"  `height` int DEFAULT NULL,\n"+
+		"  `weight` int DEFAULT NULL,\n"+
+		"  `age` int DEFAULT NULL,\n"+
+		"  `description` text,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", QuoteIdentifier(table))
+}
+
+// InsertStatement returns the statement that inserts the row into the table. The id is assigned by the server.
+func InsertStatement(table string, row Row) string {
+	return fmt.Sprintf("INSERT INTO %s (name,height,weight,age,description) VALUES (%q,%d,%d,%d,%q)",
+		table,
+		row.Name,
+		row.Height,
+		row.Weight,
+		row.Age,
+		row.Description,
+	)
+}
+
+// BatchSeed derives the seed of a batch using the splitmix64 finalizer so that
+// the seeds of adjacent workers and batches are not correlated
+func BatchSeed(seed int64, worker int, batch int64) int64 {
+	z := uint64(seed) + uint64(worker+1)*0x9E3779B97F4A7C15 + uint64(batch)*0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return int64(z ^ (z >> 31))
+}
+
+// ParseSize parses a size with a unit (i.e. 512MB). The units are powers of 1024.
+func ParseSize(size string) (int64, error) {
+	var amount float64
+	var unit string
+	_, err := fmt.Sscanf(size, "%f%s", &amount, &unit)
+	if err != nil {
+		return 0, err
+	}
+
+	switch unit {
+	case "KB", "K":
+		return int64(amount * 1024), nil
+	case "MB", "Mi":
+		return int64(amount * 1024 * 1024), nil
+	case "GB", "Gi":
+		return int64(amount * 1024 * 1024 * 1024), nil
+	default:
+		return 0, fmt.Errorf("expected data unit to one of (KB,K, MB,Mi, GB,Gi). Found: %s", unit)
+	}
+}
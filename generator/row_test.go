@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{size: "512KB", want: 512 * 1024},
+		{size: "1K", want: 1024},
+		{size: "100MB", want: 100 * 1024 * 1024},
+		{size: "2Mi", want: 2 * 1024 * 1024},
+		{size: "5GB", want: 5 * 1024 * 1024 * 1024},
+		{size: "1Gi", want: 1024 * 1024 * 1024},
+		{size: "1.5MB", want: 1536 * 1024},
+		{size: "0.5KB", want: 512},
+		{size: "10TB", wantErr: true},
+		{size: "10", wantErr: true},
+		{size: "MB", wantErr: true},
+		{size: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.size)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseSize(%q) = %d, want an error", test.size, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSize(%q) failed: %v", test.size, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", test.size, got, test.want)
+		}
+	}
+}
+
+func TestNewRow(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 1000; i++ {
+		row := NewRow(r)
+		if row.ID != 0 {
+			t.Fatalf("row %d has id %d, want 0 as the id is assigned by the sink", i, row.ID)
+		}
+		if row.Height < 120 || row.Height > 200 {
+			t.Errorf("row %d has height %d, want 120-200", i, row.Height)
+		}
+		if row.Weight < 30 || row.Weight > 230 {
+			t.Errorf("row %d has weight %d, want 30-230", i, row.Weight)
+		}
+		if row.Age < 10 || row.Age > 110 {
+			t.Errorf("row %d has age %d, want 10-110", i, row.Age)
+		}
+		if len(strings.Fields(row.Name)) < 2 {
+			t.Errorf("row %d has name %q, want an adjective and a noun", i, row.Name)
+		}
+		if row.Description != LoremIpsum {
+			t.Errorf("row %d has an unexpected description", i)
+		}
+	}
+}
+
+func TestNewRowIsDeterministic(t *testing.T) {
+	a := rand.New(rand.NewSource(7))
+	b := rand.New(rand.NewSource(7))
+	for i := 0; i < 100; i++ {
+		if x, y := NewRow(a), NewRow(b); x != y {
+			t.Fatalf("row %d differs with the same seed: %+v != %+v", i, x, y)
+		}
+	}
+}
+
+func TestBatchSeed(t *testing.T) {
+	seeds := map[int64]bool{}
+	for worker := 0; worker < 10; worker++ {
+		for batch := int64(0); batch < 100; batch++ {
+			seed := BatchSeed(1, worker, batch)
+			if seeds[seed] {
+				t.Fatalf("BatchSeed(1, %d, %d) = %d is not unique", worker, batch, seed)
+			}
+			seeds[seed] = true
+		}
+	}
+	if BatchSeed(1, 2, 3) != BatchSeed(1, 2, 3) {
+		t.Error("BatchSeed is not deterministic")
+	}
+	if BatchSeed(1, 0, 0) == BatchSeed(2, 0, 0) {
+		t.Error("BatchSeed does not depend on the seed")
+	}
+}
+
+func TestRowValuesAndSet(t *testing.T) {
+	row := Row{ID: 3, Name: "Brave Lion", Height: 150, Weight: 60, Age: 30, Description: "text"}
+	if got, want := row.Values(), []interface{}{int64(3), "Brave Lion", 150, 60, 30, "text"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+	if len(row.Values()) != len(Columns) {
+		t.Errorf("Values() has %d values for %d columns", len(row.Values()), len(Columns))
+	}
+
+	row.Set("name", "Calm Owl")
+	row.Set("height", 121)
+	row.Set("weight", 31)
+	row.Set("age", 11)
+	row.Set("description", "other")
+	want := Row{ID: 3, Name: "Calm Owl", Height: 121, Weight: 31, Age: 11, Description: "other"}
+	if row != want {
+		t.Errorf("Set() = %+v, want %+v", row, want)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	row := Row{Name: `Brave "Lion"`, Height: 150, Weight: 60, Age: 30, Description: "text"}
+	if got, want := InsertStatement("table0", row), `INSERT INTO table0 (name,height,weight,age,description) VALUES ("Brave \"Lion\"",150,60,30,"text")`; got != want {
+		t.Errorf("InsertStatement() = %s, want %s", got, want)
+	}
+	if got := CreateTableStatement("table1"); !strings.HasPrefix(got, "CREATE TABLE table1 (id int NOT NULL AUTO_INCREMENT PRIMARY KEY,") {
+		t.Errorf("CreateTableStatement() = %s", got)
+	}
+	if got := TableName(2); got != "table2" {
+		t.Errorf("TableName(2) = %s, want table2", got)
+	}
+}
+
+func TestMeanNameLength(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var total int
+	const samples = 100000
+	for i := 0; i < samples; i++ {
+		total += len(Name(r))
+	}
+	mean := float64(total) / samples
+	if expected := MeanNameLength(); mean < expected*0.98 || mean > expected*1.02 {
+		t.Errorf("the mean length of %d names is %.2f, MeanNameLength() = %.2f", samples, mean, expected)
+	}
+}
@@ -0,0 +1,165 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Sink writes the generated rows, i.e. to a MySQL server or to files. The
+// methods are called concurrently by the workers of a Generator.
+type Sink interface {
+	// CreateTables creates the database and the tables that do not exist. With
+	// overwrite, the existing database is dropped first.
+	CreateTables(ctx context.Context, database string, tables []string, overwrite bool) error
+	// Write writes a batch of rows to a table as a whole and sets the ids of the
+	// rows. It is not cancelled with the generation, so that a batch is never
+	// written in part.
+	Write(database, table string, rows []Row) error
+}
+
+// TableRows are rows written to a table
+type TableRows struct {
+	Table string
+	Rows  []Row
+}
+
+// MultiTableWriter is implemented by the sinks that can write the rows of several
+// tables as a whole. Options.MultiTableRatio requires it.
+type MultiTableWriter interface {
+	// WriteTables writes the rows in order and sets their ids, like Write
+	WriteTables(database string, batch []TableRows) error
+}
+
+// Measurer is implemented by the sinks that can measure the size of the written
+// data. Options.Size requires it.
+type Measurer interface {
+	Size(ctx context.Context, database string, tables []string) (int64, error)
+}
+
+// Checker is implemented by the sinks that can read the written rows back. Verify requires it.
+type Checker interface {
+	// Existing returns the ids of the table that exist
+	Existing(ctx context.Context, database, table string, ids []int64) (map[int64]bool, error)
+}
+
+// MySQLSink inserts the rows into a MySQL server. A batch of several rows is
+// inserted in a transaction, a single row in autocommit mode.
+type MySQLSink struct {
+	db *sql.DB
+	// Isolation is the isolation level of the transactions. The default of the server if zero.
+	Isolation sql.IsolationLevel
+}
+
+// NewMySQLSink returns a sink that uses the client. The client is not closed by the sink.
+func NewMySQLSink(db *sql.DB) *MySQLSink {
+	return &MySQLSink{db: db}
+}
+
+func qualifiedName(database, table string) string {
+	return QuoteIdentifier(database) + "." + QuoteIdentifier(table)
+}
+
+func (s *MySQLSink) CreateTables(ctx context.Context, database string, tables []string, overwrite bool) error {
+	if overwrite {
+		if _, err := s.db.ExecContext(ctx, "DROP DATABASE IF EXISTS "+QuoteIdentifier(database)); err != nil {
+			return fmt.Errorf("failed to drop database %q. Reason: %w", database, err)
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, "CREATE DATABASE IF NOT EXISTS "+QuoteIdentifier(database)); err != nil {
+		return fmt.Errorf("failed to create database %q. Reason: %w", database, err)
+	}
+	for _, table := range tables {
+		statement := strings.Replace(CreateTableStatement(qualifiedName(database, table)), "CREATE TABLE ", "CREATE TABLE IF NOT EXISTS ", 1)
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create table %q. Reason: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func (s *MySQLSink) Write(database, table string, rows []Row) error {
+	return s.WriteTables(database, []TableRows{{Table: table, Rows: rows}})
+}
+
+func (s *MySQLSink) WriteTables(database string, batch []TableRows) error {
+	count := 0
+	for _, t := range batch {
+		count += len(t.Rows)
+	}
+	if count == 1 {
+		for _, t := range batch {
+			if len(t.Rows) == 1 {
+				res, err := s.db.Exec(InsertStatement(qualifiedName(database, t.Table), t.Rows[0]))
+				if err != nil {
+					return err
+				}
+				t.Rows[0].ID, err = res.LastInsertId()
+				return err
+			}
+		}
+	}
+
+	// the transaction is not bound to a context so that it is never cut in half
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: s.Isolation})
+	if err != nil {
+		return err
+	}
+	for _, t := range batch {
+		name := qualifiedName(database, t.Table)
+		for i := range t.Rows {
+			res, err := tx.Exec(InsertStatement(name, t.Rows[i]))
+			if err == nil {
+				t.Rows[i].ID, err = res.LastInsertId()
+			}
+			if err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Size returns the size of the data and the indexes of the database according to
+// the table statistics, which are refreshed first.
+// refs:
+// - https://dba.stackexchange.com/questions/236863/wrong-innodb-table-status-size-rows-after-updating-from-mysql-5-7-to-8
+// - https://dev.mysql.com/doc/refman/8.0/en/check-table.html
+// - https://dev.mysql.com/doc/refman/8.0/en/analyze-table.html
+func (s *MySQLSink) Size(ctx context.Context, database string, tables []string) (int64, error) {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = qualifiedName(database, table)
+	}
+	for _, statement := range []string{"CHECK TABLE ", "ANALYZE TABLE "} {
+		if _, err := s.db.ExecContext(ctx, statement+strings.Join(names, ",")); err != nil {
+			return 0, err
+		}
+	}
+	var size int64
+	err := s.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.TABLES WHERE table_schema = ?", database).Scan(&size)
+	return size, err
+}
+
+func (s *MySQLSink) Existing(ctx context.Context, database, table string, ids []int64) (map[int64]bool, error) {
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = fmt.Sprint(id)
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT id FROM %s WHERE id IN (%s)", qualifiedName(database, table), strings.Join(list, ",")))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		found[id] = true
+	}
+	return found, rows.Err()
+}
@@ -0,0 +1,448 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/hossainemruz/mysql-data-generator/internal/fakemysql"
+)
+
+func TestMySQLSinkCreateTables(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	sink := NewMySQLSink(db)
+	ctx := context.Background()
+
+	if err := sink.CreateTables(ctx, "db", []string{"table0", "table1"}, false); err != nil {
+		t.Fatalf("CreateTables() failed: %v", err)
+	}
+	if got := server.Tables("db"); len(got) != 2 || got[0] != "table0" || got[1] != "table1" {
+		t.Fatalf("created tables %v, want [table0 table1]", got)
+	}
+	rows := []Row{{Name: "a"}, {Name: "b"}}
+	if err := sink.Write("db", "table0", rows); err != nil {
+		t.Fatal(err)
+	}
+
+	// the existing tables are kept
+	if err := sink.CreateTables(ctx, "db", []string{"table0", "table1"}, false); err != nil {
+		t.Fatalf("CreateTables() of existing tables failed: %v", err)
+	}
+	if got := len(server.Rows("db", "table0")); got != 2 {
+		t.Errorf("table0 has %d rows after creating it again, want 2", got)
+	}
+
+	// overwrite drops the database
+	if err := sink.CreateTables(ctx, "db", []string{"table0"}, true); err != nil {
+		t.Fatalf("CreateTables() with overwrite failed: %v", err)
+	}
+	if got := server.Tables("db"); len(got) != 1 || len(server.Rows("db", "table0")) != 0 {
+		t.Errorf("database has tables %v with %d rows after overwriting, want an empty table0", got, len(server.Rows("db", "table0")))
+	}
+}
+
+func TestMySQLSinkCreateTablesError(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	denied := &mysql.MySQLError{Number: 1142, Message: "CREATE command denied to user"}
+	server.Fail("CREATE TABLE", 1, denied)
+
+	err := NewMySQLSink(db).CreateTables(context.Background(), "db", []string{"table0"}, false)
+	if !errors.Is(err, denied) {
+		t.Errorf("CreateTables() = %v, want %v", err, denied)
+	}
+}
+
+func TestMySQLSinkWrite(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	sink := NewMySQLSink(db)
+	if err := sink.CreateTables(context.Background(), "db", []string{"table0"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	row := []Row{{Name: `Brave "Lion"`, Height: 150, Weight: 60, Age: 30, Description: "line\nbreak"}}
+	if err := sink.Write("db", "table0", row); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if row[0].ID != 1 {
+		t.Errorf("the row has id %d, want 1", row[0].ID)
+	}
+	stored := server.Rows("db", "table0")
+	if len(stored) != 1 || stored[0]["name"] != `Brave "Lion"` || stored[0]["description"] != "line\nbreak" || stored[0]["height"] != int64(150) {
+		t.Errorf("stored rows are %v", stored)
+	}
+
+	batch := []Row{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	if err := sink.Write("db", "table0", batch); err != nil {
+		t.Fatalf("Write() of a batch failed: %v", err)
+	}
+	for i, row := range batch {
+		if row.ID != int64(i+2) {
+			t.Errorf("row %d has id %d, want %d", i, row.ID, i+2)
+		}
+	}
+	for _, statement := range server.Statements() {
+		if strings.Contains(statement, "INSERT") && !strings.Contains(statement, "`db`.`table0`") {
+			t.Errorf("the insert %q does not use the qualified table name", statement)
+		}
+	}
+}
+
+func TestMySQLSinkWriteRollsBack(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	sink := NewMySQLSink(db)
+	if err := sink.CreateTables(context.Background(), "db", []string{"table0"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+	server.Fail("Third", 1, deadlock)
+	err := sink.Write("db", "table0", []Row{{Name: "First"}, {Name: "Second"}, {Name: "Third"}})
+	if !errors.Is(err, deadlock) {
+		t.Fatalf("Write() = %v, want %v", err, deadlock)
+	}
+	if got := len(server.Rows("db", "table0")); got != 0 {
+		t.Errorf("table0 has %d rows after the failed transaction, want 0", got)
+	}
+}
+
+func TestMySQLSinkWriteTables(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	sink := NewMySQLSink(db)
+	if err := sink.CreateTables(context.Background(), "db", []string{"table0", "table1"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := []TableRows{{Table: "table1", Rows: []Row{{Name: "Parent"}}}, {Table: "table0", Rows: []Row{{Name: "Child"}}}, {Table: "table1", Rows: []Row{{Name: "Child"}}}}
+	if err := sink.WriteTables("db", batch); err != nil {
+		t.Fatalf("WriteTables() failed: %v", err)
+	}
+	if batch[0].Rows[0].ID != 1 || batch[1].Rows[0].ID != 1 || batch[2].Rows[0].ID != 2 {
+		t.Errorf("the rows have the ids %d, %d and %d, want 1, 1 and 2", batch[0].Rows[0].ID, batch[1].Rows[0].ID, batch[2].Rows[0].ID)
+	}
+	var inserts []string
+	for _, statement := range server.Statements() {
+		if strings.HasPrefix(statement, "INSERT") {
+			inserts = append(inserts, statement)
+		}
+	}
+	if len(inserts) != 3 || !strings.Contains(inserts[0], "`table1`") || !strings.Contains(inserts[1], "`table0`") {
+		t.Errorf("the inserts are %q, want the parent first", inserts)
+	}
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+	server.Fail("Second Child", 1, deadlock)
+	err := sink.WriteTables("db", []TableRows{{Table: "table0", Rows: []Row{{Name: "Second Parent"}}}, {Table: "table1", Rows: []Row{{Name: "Second Child"}}}})
+	if !errors.Is(err, deadlock) {
+		t.Fatalf("WriteTables() = %v, want %v", err, deadlock)
+	}
+	if len(server.Rows("db", "table0")) != 1 || len(server.Rows("db", "table1")) != 2 {
+		t.Error("the rows of the failed transaction have not been rolled back")
+	}
+}
+
+func TestMySQLSinkSizeAndExisting(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	sink := NewMySQLSink(db)
+	ctx := context.Background()
+	if err := sink.CreateTables(ctx, "db", []string{"table0"}, false); err != nil {
+		t.Fatal(err)
+	}
+	size, err := sink.Size(ctx, "db", []string{"table0"})
+	if err != nil || size != 0 {
+		t.Errorf("Size() of an empty table = %d, %v, want 0", size, err)
+	}
+
+	rows := []Row{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	if err := sink.Write("db", "table0", rows); err != nil {
+		t.Fatal(err)
+	}
+	size, err = sink.Size(ctx, "db", []string{"table0"})
+	if err != nil || size != server.Size("db") || size == 0 {
+		t.Errorf("Size() = %d, %v, want %d", size, err, server.Size("db"))
+	}
+
+	server.DeleteRows("db", "table0", 2)
+	found, err := sink.Existing(ctx, "db", "table0", []int64{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found[1] || found[2] || !found[3] || found[4] {
+		t.Errorf("Existing() = %v, want ids 1 and 3", found)
+	}
+}
+
+func TestGenerateWithMySQL(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	gen, err := New(db, Options{Database: "e2e", Tables: 2, Rows: 60, Concurrency: 3, TxnRows: 4, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gen.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if result.Rows != 60 {
+		t.Errorf("inserted %d rows, want 60", result.Rows)
+	}
+	if got := len(server.Rows("e2e", "table0")) + len(server.Rows("e2e", "table1")); got != 60 {
+		t.Errorf("the tables have %d rows, want 60", got)
+	}
+	if err := gen.Verify(context.Background()); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+
+	server.DeleteRows("e2e", "table0", 1)
+	var missing *MissingRowsError
+	if err := gen.Verify(context.Background()); !errors.As(err, &missing) || len(missing.Missing["table0"]) != 1 {
+		t.Errorf("Verify() = %v, want the missing row 1 of table0", err)
+	}
+}
+
+func TestGenerateWithMySQLStopsAtSize(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	gen, err := New(db, Options{Size: 64 * 1024, Concurrency: 2, ProgressInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(context.Background()); err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if size := server.Size(DefaultDatabase); size < 64*1024 {
+		t.Errorf("stopped at %d bytes, want at least %d", size, 64*1024)
+	}
+}
+
+func TestGenerateWithMySQLReadOnly(t *testing.T) {
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	gen, err := New(db, Options{Rows: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetReadOnly(true)
+	_, err = gen.Generate(context.Background())
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != fakemysql.ErrOptionPreventsRun {
+		t.Errorf("Generate() on a read-only server = %v, want error %d", err, fakemysql.ErrOptionPreventsRun)
+	}
+}
+
+func TestSQLSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSQLSink(&buf)
+	if err := sink.Write("db", "table0", []Row{{}}); err == nil {
+		t.Error("Write() to a table that has not been created succeeded, want an error")
+	}
+	if err := sink.CreateTables(context.Background(), "db", []string{"table0"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write("db", "table0", []Row{{Name: "it's", Height: 1, Weight: 2, Age: 3, Description: "d"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write("db", "table0", []Row{{Name: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		"-- Host: (offline)    Database: db\n",
+		"/*!40000 DROP DATABASE IF EXISTS `db`*/;\n\nCREATE DATABASE /*!32312 IF NOT EXISTS*/ `db` /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\n\nUSE `db`;\n",
+		"DROP TABLE IF EXISTS `table0`;\n",
+		DumpTableStatement("table0") + ";\n",
+		"LOCK TABLES `table0` WRITE;\n/*!40000 ALTER TABLE `table0` DISABLE KEYS */;\n" +
+			"INSERT INTO `table0` VALUES (1,'it\\'s',1,2,3,'d'),(2,'b',0,0,0,'');\n" +
+			"/*!40000 ALTER TABLE `table0` ENABLE KEYS */;\nUNLOCK TABLES;\n",
+		DumpFooter + "\n-- Dump completed on ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SQLSink wrote\n%s\nwhich does not contain\n%s", got, want)
+		}
+	}
+	if size, _ := sink.Size(context.Background(), "db", nil); size != int64(len(got)) {
+		t.Errorf("Size() = %d, want %d", size, len(got))
+	}
+}
+
+func TestSQLSinkSplitsInserts(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSQLSink(&buf)
+	if err := sink.CreateTables(context.Background(), "db", []string{"table0"}, false); err != nil {
+		t.Fatal(err)
+	}
+	description := strings.Repeat("x", maxInsertSize/3)
+	rows := []Row{{Description: description}, {Description: description}, {Description: description}}
+	if err := sink.Write("db", "table0", rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if len(line) > maxInsertSize {
+			t.Errorf("the dump has a statement of %d bytes, want at most %d", len(line), maxInsertSize)
+		}
+	}
+	if got := strings.Count(buf.String(), "INSERT INTO"); got != 2 {
+		t.Errorf("the dump has %d INSERT statements, want 2", got)
+	}
+}
+
+func TestSQLSinkInterleavedTables(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSQLSink(&buf)
+	if err := sink.CreateTables(context.Background(), "db", []string{"table0", "table1"}, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"table0", "table1", "table0"} {
+		if err := sink.Write("db", table, []Row{{Name: table}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.WriteTables("db", []TableRows{{Table: "table1", Rows: []Row{{Name: "parent"}}}, {Table: "table0", Rows: []Row{{Name: "child"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rows of each table are packed into a single statement
+	got := buf.String()
+	for _, want := range []string{
+		"INSERT INTO `table0` VALUES (1,'table0',0,0,0,''),(2,'table0',0,0,0,''),(3,'child',0,0,0,'');\n",
+		"INSERT INTO `table1` VALUES (1,'table1',0,0,0,''),(2,'parent',0,0,0,'');\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SQLSink wrote\n%s\nwhich does not contain\n%s", got, want)
+		}
+	}
+	if count := strings.Count(got, "\nLOCK TABLES"); count != 2 {
+		t.Errorf("the dump has %d data sections, want 2", count)
+	}
+}
+
+func TestSQLSinkReplay(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSQLSink(&buf)
+	gen, err := NewWithSink(sink, Options{Tables: 2, Rows: 20, TxnRows: 3, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// replay the statements like the mysql client, which runs the versioned
+	// comments. The session settings are not supported by the fake server.
+	server := fakemysql.New()
+	db := server.DB("")
+	defer db.Close()
+	versioned := regexp.MustCompile(`/\*!\d+ ?(.*?)\*/`)
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.HasPrefix(line, "--") {
+			lines = append(lines, line)
+		}
+	}
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";\n") {
+		statement = strings.TrimSpace(versioned.ReplaceAllString(statement, "$1"))
+		switch {
+		case statement == "", strings.HasPrefix(statement, "SET "), strings.HasPrefix(statement, "LOCK "),
+			strings.HasPrefix(statement, "UNLOCK "), strings.HasPrefix(statement, "ALTER "):
+			continue
+		}
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("failed to replay %.80q: %v", statement, err)
+		}
+	}
+	if got := len(server.Rows(DefaultDatabase, "table0")) + len(server.Rows(DefaultDatabase, "table1")); got != 20 {
+		t.Errorf("the replayed tables have %d rows, want 20", got)
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewCSVSink(dir)
+	if err := sink.CreateTables(context.Background(), "db", []string{"table0", "table1"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write("db", "table0", []Row{{Name: `a "b"`, Height: 1, Weight: 2, Age: 3, Description: "d"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write("db", "table2", []Row{{}}); err == nil {
+		t.Error("Write() to a table that has not been created succeeded, want an error")
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "table0.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "1,\"a \\\"b\\\"\",1,2,3,\"d\"\n"; got != want {
+		t.Errorf("table0.csv is %q, want %q", got, want)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "table1.csv")); err != nil || len(data) != 0 {
+		t.Errorf("table1.csv is %q, %v, want an empty file", data, err)
+	}
+}
+
+func TestCSVSinkFormat(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewCSVSink(dir)
+	sink.Format, sink.Header, sink.Extension = TSV, true, "tsv"
+	if err := sink.CreateTables(context.Background(), "db", []string{"table0"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write("db", "table0", []Row{{Name: "a\tb", Height: 1, Weight: 2, Age: 3, Description: "d"}}); err != nil {
+		t.Fatal(err)
+	}
+	size, err := sink.Size(context.Background(), "db", []string{"table0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "table0.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("Size() = %d, want the %d bytes of the file with its header", size, len(data))
+	}
+	if got, want := string(data), "id\tname\theight\tweight\tage\tdescription\n1\ta\\tb\t1\t2\t3\td\n"; got != want {
+		t.Errorf("table0.tsv is %q, want %q", got, want)
+	}
+}
@@ -3,3 +3,6 @@ module github.com/hossainemruz/mysql-data-generator
 go 1.15
 
 require github.com/go-sql-driver/mysql v1.5.0
+
+// v1.5.0 with the connectionAttributes parameter backported, see third_party/go-sql-driver/mysql/CHANGELOG.md
+replace github.com/go-sql-driver/mysql => ./third_party/go-sql-driver/mysql
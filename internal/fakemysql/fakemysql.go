@@ -0,0 +1,370 @@
+// Package fakemysql is an in-process stand-in of a MySQL server for the tests. It
+// implements a database/sql driver that keeps the databases in memory and
+// understands the statements issued by the generator: creating and dropping
+// databases and tables, inserts, transactions, the table statistics and the
+// lookups of rows by id. The statements can be made to fail with the errors of
+// a real server to test the error handling.
+//
+// The uncommitted rows of a transaction are visible to the other connections
+// and are removed again by a rollback.
+package fakemysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL errors returned by the server
+const (
+	ErrDatabaseExists      = 1007 // ER_DB_CREATE_EXISTS
+	ErrDropMissingDatabase = 1008 // ER_DB_DROP_EXISTS
+	ErrNoDatabaseSelected  = 1046 // ER_NO_DB_ERROR
+	ErrUnknownDatabase     = 1049 // ER_BAD_DB_ERROR
+	ErrTableExists         = 1050 // ER_TABLE_EXISTS_ERROR
+	ErrUnknownTable        = 1051 // ER_BAD_TABLE_ERROR
+	ErrUnknownColumn       = 1054 // ER_BAD_FIELD_ERROR
+	ErrDuplicateKey        = 1062 // ER_DUP_ENTRY
+	ErrSyntax              = 1064 // ER_PARSE_ERROR
+	ErrColumnCount         = 1136 // ER_WRONG_VALUE_COUNT_ON_ROW
+	ErrTableNotFound       = 1146 // ER_NO_SUCH_TABLE
+	ErrOptionPreventsRun   = 1290 // ER_OPTION_PREVENTS_STATEMENT
+)
+
+// Row is a row of a table by column
+type Row map[string]interface{}
+
+// Server holds the databases. It is safe for concurrent use.
+type Server struct {
+	mu         sync.Mutex
+	databases  map[string]*database
+	failures   []*failure
+	readOnly   bool
+	statements []string
+}
+
+type database struct {
+	tables map[string]*table
+}
+
+type table struct {
+	columns       []string
+	autoIncrement string
+	rows          []Row
+	nextID        int64
+}
+
+// failure makes the statements that contain match fail
+type failure struct {
+	match string
+	times int
+	err   error
+}
+
+// New returns a server without databases
+func New() *Server {
+	return &Server{databases: map[string]*database{}}
+}
+
+// Connector returns a connector of the clients, which use the database by default
+func (s *Server) Connector(database string) driver.Connector {
+	return &connector{server: s, database: database}
+}
+
+// DB returns a client that uses the database by default
+func (s *Server) DB(database string) *sql.DB {
+	return sql.OpenDB(s.Connector(database))
+}
+
+// Fail makes the next times statements that contain match fail with err. A
+// negative times makes all of them fail until ClearFailures is called. A
+// statement of "PING" matches the pings of the clients.
+func (s *Server) Fail(match string, times int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{match: match, times: times, err: err})
+}
+
+// ClearFailures makes the statements succeed again
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+// SetReadOnly makes the writes fail like on a server with super_read_only, i.e. a replica
+func (s *Server) SetReadOnly(readOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readOnly = readOnly
+}
+
+// Statements returns the statements executed so far
+func (s *Server) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// Databases returns the names of the databases
+func (s *Server) Databases() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.databases))
+	for name := range s.databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Tables returns the names of the tables of the database
+func (s *Server) Tables(database string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, ok := s.databases[database]
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(db.tables))
+	for name := range db.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rows returns the rows of the table in the order they have been inserted
+func (s *Server) Rows(database, name string) []Row {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.table(database, name)
+	if err != nil {
+		return nil
+	}
+	rows := make([]Row, len(t.rows))
+	for i, row := range t.rows {
+		rows[i] = Row{}
+		for column, value := range row {
+			rows[i][column] = value
+		}
+	}
+	return rows
+}
+
+// DeleteRows deletes the rows with the ids from the table, i.e. to simulate a data loss
+func (s *Server) DeleteRows(database, name string, ids ...int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, err := s.table(database, name); err == nil {
+		t.delete(ids)
+	}
+}
+
+// Size returns the size of the data of the database, which is reported as the
+// data length of the tables. Integers count as 4 bytes, strings as their length.
+func (s *Server) Size(database string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size(database)
+}
+
+func (s *Server) size(database string) int64 {
+	db, ok := s.databases[database]
+	if !ok {
+		return 0
+	}
+	var size int64
+	for _, t := range db.tables {
+		size += t.size()
+	}
+	return size
+}
+
+// table returns the table, which must be called with the lock held
+func (s *Server) table(database, name string) (*table, error) {
+	if db, ok := s.databases[database]; ok {
+		if t, ok := db.tables[name]; ok {
+			return t, nil
+		}
+	}
+	return nil, mysqlError(ErrTableNotFound, "Table '%s.%s' doesn't exist", database, name)
+}
+
+// fail returns the error of the first failure that matches the statement
+func (s *Server) fail(statement string) error {
+	for i, f := range s.failures {
+		if !strings.Contains(statement, f.match) {
+			continue
+		}
+		if f.times > 0 {
+			f.times--
+			if f.times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f.err
+	}
+	return nil
+}
+
+func (t *table) delete(ids []int64) {
+	deleted := map[int64]bool{}
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	rows := t.rows[:0]
+	for _, row := range t.rows {
+		if id, ok := row[t.autoIncrement].(int64); !ok || !deleted[id] {
+			rows = append(rows, row)
+		}
+	}
+	t.rows = rows
+}
+
+func mysqlError(number uint16, format string, args ...interface{}) error {
+	return &mysql.MySQLError{Number: number, Message: fmt.Sprintf(format, args...)}
+}
+
+type connector struct {
+	server   *Server
+	database string
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{server: c.server, database: c.database}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakemysql: open the clients with Server.DB or Server.Connector")
+}
+
+// conn is a connection of a client. A connection is used by a single goroutine at a time.
+type conn struct {
+	server   *Server
+	database string
+	// undo reverts the inserts of the running transaction
+	undo []func()
+	inTx bool
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.inTx = true
+	c.undo = nil
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	return c.server.fail("PING")
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, _, err := c.run(query, args)
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	_, rows, err := c.run(query, args)
+	if err == nil && rows == nil {
+		rows = &resultRows{}
+	}
+	return rows, err
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	t.conn.inTx = false
+	t.conn.undo = nil
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.conn.server.mu.Lock()
+	defer t.conn.server.mu.Unlock()
+	for i := len(t.conn.undo) - 1; i >= 0; i-- {
+		t.conn.undo[i]()
+	}
+	t.conn.inTx = false
+	t.conn.undo = nil
+	return nil
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type resultRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *resultRows) Columns() []string { return r.columns }
+func (r *resultRows) Close() error      { return nil }
+
+func (r *resultRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
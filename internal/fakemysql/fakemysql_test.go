@@ -0,0 +1,238 @@
+package fakemysql
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// errorNumber returns the MySQL error number of err, or 0
+func errorNumber(err error) uint16 {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number
+	}
+	return 0
+}
+
+func mustExec(t *testing.T, db *sql.DB, statement string, args ...interface{}) sql.Result {
+	t.Helper()
+	res, err := db.Exec(statement, args...)
+	if err != nil {
+		t.Fatalf("%s failed: %v", statement, err)
+	}
+	return res
+}
+
+func TestSchema(t *testing.T) {
+	server := New()
+	db := server.DB("")
+	defer db.Close()
+
+	mustExec(t, db, "CREATE DATABASE sampleData;")
+	if _, err := db.Exec("CREATE DATABASE sampleData;"); errorNumber(err) != ErrDatabaseExists {
+		t.Errorf("creating an existing database = %v, want error %d", err, ErrDatabaseExists)
+	}
+	mustExec(t, db, "CREATE DATABASE IF NOT EXISTS `sampleData`")
+	if _, err := db.Exec("CREATE TABLE table0 (id int)"); errorNumber(err) != ErrNoDatabaseSelected {
+		t.Errorf("creating a table without a database = %v, want error %d", err, ErrNoDatabaseSelected)
+	}
+
+	mustExec(t, db, "USE sampleData")
+	mustExec(t, db, "CREATE TABLE table0 (id int NOT NULL AUTO_INCREMENT PRIMARY KEY,name text, height int)")
+	if _, err := db.Exec("CREATE TABLE table0 (id int)"); errorNumber(err) != ErrTableExists {
+		t.Errorf("creating an existing table = %v, want error %d", err, ErrTableExists)
+	}
+	mustExec(t, db, "CREATE TABLE IF NOT EXISTS `sampleData`.`table0` (id int)")
+	mustExec(t, db, "CREATE TABLE `table1` (\n  `id` int NOT NULL,\n  `data` text,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB")
+	if got := server.Tables("sampleData"); len(got) != 2 {
+		t.Errorf("tables are %v, want [table0 table1]", got)
+	}
+
+	mustExec(t, db, "DROP TABLE table1")
+	if _, err := db.Exec("DROP TABLE table1"); errorNumber(err) != ErrUnknownTable {
+		t.Errorf("dropping a missing table = %v, want error %d", err, ErrUnknownTable)
+	}
+	mustExec(t, db, "DROP DATABASE IF EXISTS sampleData;")
+	if got := server.Databases(); len(got) != 0 {
+		t.Errorf("databases are %v after dropping, want none", got)
+	}
+	if _, err := db.Exec("DROP DATABASE sampleData"); errorNumber(err) != ErrDropMissingDatabase {
+		t.Errorf("dropping a missing database = %v, want error %d", err, ErrDropMissingDatabase)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	server := New()
+	db := server.DB("db")
+	defer db.Close()
+	mustExec(t, db, "CREATE DATABASE db")
+	mustExec(t, db, "CREATE TABLE t (id int NOT NULL AUTO_INCREMENT PRIMARY KEY, name text, age int)")
+
+	res := mustExec(t, db, `INSERT INTO t (name,age) VALUES ("say \"hi\"\n",30)`)
+	if id, _ := res.LastInsertId(); id != 1 {
+		t.Errorf("LastInsertId() = %d, want 1", id)
+	}
+	res = mustExec(t, db, "INSERT INTO `db`.`t` VALUES (5,'it\\'s',NULL),(NULL,'x''y',7)")
+	if id, _ := res.LastInsertId(); id != 6 {
+		t.Errorf("LastInsertId() = %d, want 6", id)
+	}
+	mustExec(t, db, "INSERT INTO t (name) VALUES (?)", "bound ' value")
+
+	rows := server.Rows("db", "t")
+	want := []Row{
+		{"id": int64(1), "name": "say \"hi\"\n", "age": int64(30)},
+		{"id": int64(5), "name": "it's", "age": nil},
+		{"id": int64(6), "name": "x'y", "age": int64(7)},
+		{"id": int64(7), "name": "bound ' value"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("table has %d rows, want %d", len(rows), len(want))
+	}
+	for i := range want {
+		for column, value := range want[i] {
+			if rows[i][column] != value {
+				t.Errorf("row %d has %s = %#v, want %#v", i, column, rows[i][column], value)
+			}
+		}
+	}
+
+	tests := map[string]uint16{
+		"INSERT INTO t VALUES (1,'dup',1)":         ErrDuplicateKey,
+		"INSERT INTO t (name) VALUES ('a','b')":    ErrColumnCount,
+		"INSERT INTO t (color) VALUES ('red')":     ErrUnknownColumn,
+		"INSERT INTO missing (name) VALUES ('a')":  ErrTableNotFound,
+		"INSERT INTO t (name) VALUES ('unclosed)":  ErrSyntax,
+		"UPDATE t SET name = 'a' WHERE id = 1":     ErrSyntax,
+		"INSERT INTO t (name) VALUES ('a'),('b',)": ErrSyntax,
+	}
+	for statement, number := range tests {
+		if _, err := db.Exec(statement); errorNumber(err) != number {
+			t.Errorf("%s = %v, want error %d", statement, err, number)
+		}
+	}
+	if got := len(server.Rows("db", "t")); got != 4 {
+		t.Errorf("table has %d rows after the failed inserts, want 4", got)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	server := New()
+	db := server.DB("db")
+	defer db.Close()
+	mustExec(t, db, "CREATE DATABASE db")
+	mustExec(t, db, "CREATE TABLE t (id int NOT NULL AUTO_INCREMENT PRIMARY KEY, name text)")
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO t (name) VALUES ('rolled back')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(server.Rows("db", "t")); got != 0 {
+		t.Errorf("table has %d rows after the rollback, want 0", got)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO t (name) VALUES ('committed')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if rows := server.Rows("db", "t"); len(rows) != 1 || rows[0]["id"] != int64(2) {
+		t.Errorf("table has rows %v after the commit, want the row with id 2", rows)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	server := New()
+	db := server.DB("sampleData")
+	defer db.Close()
+	mustExec(t, db, "CREATE DATABASE sampleData")
+	mustExec(t, db, "CREATE TABLE table0 (id int NOT NULL AUTO_INCREMENT PRIMARY KEY, name text)")
+	mustExec(t, db, "INSERT INTO table0 (name) VALUES ('abcd'),('ef'),('g')")
+
+	var table, op, msgType, msgText string
+	if err := db.QueryRow("CHECK TABLE table0;").Scan(&table, &op, &msgType, &msgText); err != nil || msgText != "OK" {
+		t.Errorf("CHECK TABLE = %s %s %s, %v, want OK", op, msgType, msgText, err)
+	}
+
+	var schema string
+	var size int
+	if err := db.QueryRow(`SELECT table_schema, round(SUM(data_length + index_length)) FROM information_schema.TABLES WHERE table_schema = "sampleData";`).Scan(&schema, &size); err != nil {
+		t.Fatal(err)
+	}
+	if want := int(server.Size("sampleData")); schema != "sampleData" || size != want || size != 3*4+7 {
+		t.Errorf("size query = %s %d, want sampleData %d", schema, size, want)
+	}
+	var empty sql.NullInt64
+	if err := db.QueryRow("SELECT round(SUM(data_length + index_length)) FROM information_schema.TABLES WHERE table_schema = 'missing'").Scan(&empty); err != nil || empty.Valid {
+		t.Errorf("size of a missing database = %v, %v, want NULL", empty, err)
+	}
+	if err := db.QueryRow("SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.TABLES WHERE table_schema = ?", "missing").Scan(&size); err != nil || size != 0 {
+		t.Errorf("size of a missing database with COALESCE = %d, %v, want 0", size, err)
+	}
+
+	rows, err := db.Query("SELECT id FROM table0 WHERE id IN (1,3,4)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("ids are %v, want [1 3]", ids)
+	}
+
+	var superReadOnly, readOnly int
+	server.SetReadOnly(true)
+	if err := db.QueryRow("SELECT @@global.super_read_only, @@global.read_only").Scan(&superReadOnly, &readOnly); err != nil || superReadOnly != 1 || readOnly != 1 {
+		t.Errorf("read-only variables = %d %d, %v, want 1 1", superReadOnly, readOnly, err)
+	}
+	if _, err := db.Exec("INSERT INTO table0 (name) VALUES ('x')"); errorNumber(err) != ErrOptionPreventsRun {
+		t.Errorf("insert on a read-only server = %v, want error %d", err, ErrOptionPreventsRun)
+	}
+}
+
+func TestFail(t *testing.T) {
+	server := New()
+	db := server.DB("")
+	defer db.Close()
+	boom := errors.New("boom")
+
+	server.Fail("CREATE DATABASE", 2, boom)
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec("CREATE DATABASE a"); !errors.Is(err, boom) {
+			t.Errorf("attempt %d = %v, want %v", i, err, boom)
+		}
+	}
+	mustExec(t, db, "CREATE DATABASE a")
+
+	server.Fail("PING", -1, boom)
+	if err := db.Ping(); !errors.Is(err, boom) {
+		t.Errorf("Ping() = %v, want %v", err, boom)
+	}
+	server.ClearFailures()
+	if err := db.Ping(); err != nil {
+		t.Errorf("Ping() = %v after clearing the failures, want nil", err)
+	}
+	if got := server.Statements(); len(got) != 3 || got[0] != "CREATE DATABASE a" {
+		t.Errorf("statements are %q, want the 3 CREATE DATABASE", got)
+	}
+}
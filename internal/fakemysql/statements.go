@@ -0,0 +1,632 @@
+package fakemysql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	identPattern = "(`(?:[^`]|``)+`|[A-Za-z0-9_$]+)"
+	namePattern  = "(?:" + identPattern + `\.)?` + identPattern
+	quoted       = `('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*")`
+)
+
+var (
+	createDatabaseStatement = regexp.MustCompile(`(?is)^CREATE\s+DATABASE\s+(IF\s+NOT\s+EXISTS\s+)?` + identPattern)
+	dropDatabaseStatement   = regexp.MustCompile(`(?is)^DROP\s+DATABASE\s+(IF\s+EXISTS\s+)?` + identPattern + `$`)
+	createTableStatement    = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(IF\s+NOT\s+EXISTS\s+)?` + namePattern + `\s*\((.*)\)[^)]*$`)
+	dropTableStatement      = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(IF\s+EXISTS\s+)?(.+)$`)
+	insertStatement         = regexp.MustCompile(`(?is)^INSERT\s+INTO\s+` + namePattern + `\s*(?:\(([^)]*)\))?\s*VALUES\s*(.*)$`)
+	maintenanceStatement    = regexp.MustCompile(`(?is)^(CHECK|ANALYZE)\s+TABLE\s+(.+)$`)
+	selectIDsStatement      = regexp.MustCompile(`(?is)^SELECT\s+id\s+FROM\s+` + namePattern + `\s+WHERE\s+id\s+IN\s*(\([^)]*\))$`)
+	tableSizesStatement     = regexp.MustCompile(`(?is)^SELECT\s+(.+?)\s+FROM\s+information_schema\.TABLES(?:\s+WHERE\s+table_schema\s*=\s*` + quoted + `(?:\s+AND\s+table_name\s*=\s*` + quoted + `)?)?(\s+GROUP\s+BY\s+table_schema)?$`)
+	readOnlyStatement       = regexp.MustCompile(`(?is)^SELECT\s+@@global\.super_read_only\s*,\s*@@global\.read_only$`)
+	useStatement            = regexp.MustCompile(`(?is)^USE\s+` + identPattern + `$`)
+	tableName               = regexp.MustCompile("^" + namePattern + "$")
+)
+
+// run executes a statement. Queries return rows, the other statements a result.
+func (c *conn) run(query string, args []driver.NamedValue) (driver.Result, *resultRows, error) {
+	statement, err := interpolate(query, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	statement = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(statement), ";"))
+
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = append(s.statements, statement)
+	if err := s.fail(statement); err != nil {
+		return nil, nil, err
+	}
+
+	if m := createDatabaseStatement.FindStringSubmatch(statement); m != nil {
+		return c.createDatabase(m[1] != "", unquote(m[2]))
+	}
+	if m := dropDatabaseStatement.FindStringSubmatch(statement); m != nil {
+		return c.dropDatabase(m[1] != "", unquote(m[2]))
+	}
+	if m := createTableStatement.FindStringSubmatch(statement); m != nil {
+		return c.createTable(m[1] != "", c.qualify(m[2]), unquote(m[3]), m[4])
+	}
+	if m := dropTableStatement.FindStringSubmatch(statement); m != nil {
+		return c.dropTables(m[1] != "", m[2])
+	}
+	if m := insertStatement.FindStringSubmatch(statement); m != nil {
+		return c.insert(c.qualify(m[1]), unquote(m[2]), m[3], m[4])
+	}
+	if m := maintenanceStatement.FindStringSubmatch(statement); m != nil {
+		rows, err := c.maintain(strings.ToLower(m[1]), m[2])
+		return result{}, rows, err
+	}
+	if m := selectIDsStatement.FindStringSubmatch(statement); m != nil {
+		rows, err := c.selectIDs(c.qualify(m[1]), unquote(m[2]), m[3])
+		return result{}, rows, err
+	}
+	if m := tableSizesStatement.FindStringSubmatch(statement); m != nil {
+		return result{}, c.tableSizes(m[1], unquoteString(m[2]), unquoteString(m[3]), m[4] != ""), nil
+	}
+	if readOnlyStatement.MatchString(statement) {
+		readOnly := int64(0)
+		if s.readOnly {
+			readOnly = 1
+		}
+		return result{}, &resultRows{
+			columns: []string{"@@global.super_read_only", "@@global.read_only"},
+			values:  [][]driver.Value{{readOnly, readOnly}},
+		}, nil
+	}
+	if m := useStatement.FindStringSubmatch(statement); m != nil {
+		if _, ok := s.databases[unquote(m[1])]; !ok {
+			return nil, nil, mysqlError(ErrUnknownDatabase, "Unknown database '%s'", unquote(m[1]))
+		}
+		c.database = unquote(m[1])
+		return result{}, nil, nil
+	}
+	return nil, nil, mysqlError(ErrSyntax, "You have an error in your SQL syntax; the statement is not supported by fakemysql: %s", statement)
+}
+
+// writable returns an error if the server is read-only
+func (c *conn) writable() error {
+	if c.server.readOnly {
+		return mysqlError(ErrOptionPreventsRun, "The MySQL server is running with the --super-read-only option so it cannot execute this statement")
+	}
+	return nil
+}
+
+// qualify returns the database of a table name, which is the database of the connection if not given
+func (c *conn) qualify(database string) string {
+	if database == "" {
+		return c.database
+	}
+	return unquote(database)
+}
+
+func (c *conn) createDatabase(ifNotExists bool, name string) (driver.Result, *resultRows, error) {
+	if err := c.writable(); err != nil {
+		return nil, nil, err
+	}
+	s := c.server
+	if _, ok := s.databases[name]; ok {
+		if ifNotExists {
+			return result{}, nil, nil
+		}
+		return nil, nil, mysqlError(ErrDatabaseExists, "Can't create database '%s'; database exists", name)
+	}
+	s.databases[name] = &database{tables: map[string]*table{}}
+	return result{rowsAffected: 1}, nil, nil
+}
+
+func (c *conn) dropDatabase(ifExists bool, name string) (driver.Result, *resultRows, error) {
+	if err := c.writable(); err != nil {
+		return nil, nil, err
+	}
+	s := c.server
+	db, ok := s.databases[name]
+	if !ok {
+		if ifExists {
+			return result{}, nil, nil
+		}
+		return nil, nil, mysqlError(ErrDropMissingDatabase, "Can't drop database '%s'; database doesn't exist", name)
+	}
+	delete(s.databases, name)
+	return result{rowsAffected: int64(len(db.tables))}, nil, nil
+}
+
+func (c *conn) createTable(ifNotExists bool, database, name, definition string) (driver.Result, *resultRows, error) {
+	if err := c.writable(); err != nil {
+		return nil, nil, err
+	}
+	if database == "" {
+		return nil, nil, mysqlError(ErrNoDatabaseSelected, "No database selected")
+	}
+	db, ok := c.server.databases[database]
+	if !ok {
+		return nil, nil, mysqlError(ErrUnknownDatabase, "Unknown database '%s'", database)
+	}
+	if _, ok := db.tables[name]; ok {
+		if ifNotExists {
+			return result{}, nil, nil
+		}
+		return nil, nil, mysqlError(ErrTableExists, "Table '%s' already exists", name)
+	}
+
+	t := &table{nextID: 1}
+	for _, column := range splitList(definition) {
+		fields := strings.Fields(column)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PRIMARY", "KEY", "INDEX", "UNIQUE", "CONSTRAINT", "FOREIGN":
+			continue
+		}
+		t.columns = append(t.columns, unquote(fields[0]))
+		if strings.Contains(strings.ToUpper(column), "AUTO_INCREMENT") {
+			t.autoIncrement = unquote(fields[0])
+		}
+	}
+	db.tables[name] = t
+	return result{}, nil, nil
+}
+
+func (c *conn) dropTables(ifExists bool, names string) (driver.Result, *resultRows, error) {
+	if err := c.writable(); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range splitList(names) {
+		m := tableName.FindStringSubmatch(strings.TrimSpace(name))
+		if m == nil {
+			return nil, nil, mysqlError(ErrSyntax, "You have an error in your SQL syntax near '%s'", name)
+		}
+		database, table := c.qualify(m[1]), unquote(m[2])
+		if _, err := c.server.table(database, table); err != nil {
+			if ifExists {
+				continue
+			}
+			return nil, nil, mysqlError(ErrUnknownTable, "Unknown table '%s.%s'", database, table)
+		}
+		delete(c.server.databases[database].tables, table)
+	}
+	return result{}, nil, nil
+}
+
+func (c *conn) insert(database, name, columns, values string) (driver.Result, *resultRows, error) {
+	if err := c.writable(); err != nil {
+		return nil, nil, err
+	}
+	t, err := c.server.table(database, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := t.columns
+	if strings.TrimSpace(columns) != "" {
+		names = nil
+		for _, column := range splitList(columns) {
+			names = append(names, unquote(strings.TrimSpace(column)))
+		}
+	}
+	tuples, err := parseTuples(values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// validate all the rows before inserting any of them
+	rows := make([]Row, len(tuples))
+	nextID := t.nextID
+	var firstID int64
+	existing := map[int64]bool{}
+	for _, row := range t.rows {
+		if id, ok := row[t.autoIncrement].(int64); ok {
+			existing[id] = true
+		}
+	}
+	for i, tuple := range tuples {
+		if len(tuple) != len(names) {
+			return nil, nil, mysqlError(ErrColumnCount, "Column count doesn't match value count at row %d", i+1)
+		}
+		row := Row{}
+		for j, column := range names {
+			if !contains(t.columns, column) {
+				return nil, nil, mysqlError(ErrUnknownColumn, "Unknown column '%s' in 'field list'", column)
+			}
+			row[column] = tuple[j]
+		}
+		if t.autoIncrement != "" {
+			id, ok := row[t.autoIncrement].(int64)
+			if !ok {
+				id = nextID
+				row[t.autoIncrement] = id
+				if firstID == 0 {
+					firstID = id
+				}
+			}
+			if existing[id] {
+				return nil, nil, mysqlError(ErrDuplicateKey, "Duplicate entry '%d' for key '%s.PRIMARY'", id, name)
+			}
+			existing[id] = true
+			if id >= nextID {
+				nextID = id + 1
+			}
+		}
+		rows[i] = row
+	}
+	t.nextID = nextID
+	t.rows = append(t.rows, rows...)
+	if c.inTx {
+		c.undo = append(c.undo, func() { t.remove(rows) })
+	}
+	return result{lastInsertID: firstID, rowsAffected: int64(len(rows))}, nil, nil
+}
+
+// remove removes the rows from the table
+func (t *table) remove(rows []Row) {
+	removed := map[uintptr]bool{}
+	for _, row := range rows {
+		removed[reflect.ValueOf(row).Pointer()] = true
+	}
+	kept := t.rows[:0]
+	for _, row := range t.rows {
+		if !removed[reflect.ValueOf(row).Pointer()] {
+			kept = append(kept, row)
+		}
+	}
+	t.rows = kept
+}
+
+// maintain returns the result of CHECK TABLE or ANALYZE TABLE, which does nothing as the statistics are always up to date
+func (c *conn) maintain(op, names string) (*resultRows, error) {
+	rows := &resultRows{columns: []string{"Table", "Op", "Msg_type", "Msg_text"}}
+	for _, name := range splitList(names) {
+		m := tableName.FindStringSubmatch(strings.TrimSpace(name))
+		if m == nil {
+			return nil, mysqlError(ErrSyntax, "You have an error in your SQL syntax near '%s'", name)
+		}
+		database, table := c.qualify(m[1]), unquote(m[2])
+		qualified := database + "." + table
+		if _, err := c.server.table(database, table); err != nil {
+			rows.values = append(rows.values,
+				[]driver.Value{qualified, op, "Error", fmt.Sprintf("Table '%s' doesn't exist", qualified)},
+				[]driver.Value{qualified, op, "status", "Operation failed"})
+			continue
+		}
+		rows.values = append(rows.values, []driver.Value{qualified, op, "status", "OK"})
+	}
+	return rows, nil
+}
+
+func (c *conn) selectIDs(database, name, list string) (*resultRows, error) {
+	t, err := c.server.table(database, name)
+	if err != nil {
+		return nil, err
+	}
+	tuples, err := parseTuples(list)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[int64]bool{}
+	for _, value := range tuples[0] {
+		if id, ok := value.(int64); ok {
+			wanted[id] = true
+		}
+	}
+	rows := &resultRows{columns: []string{"id"}}
+	for _, row := range t.rows {
+		if id, ok := row["id"].(int64); ok && wanted[id] {
+			rows.values = append(rows.values, []driver.Value{id})
+		}
+	}
+	return rows, nil
+}
+
+// tableSizes returns the sizes of the databases from information_schema.TABLES.
+// The selected expressions other than table_schema are the size of the data.
+func (c *conn) tableSizes(selected, database, name string, group bool) *resultRows {
+	expressions := splitList(selected)
+	rows := &resultRows{}
+	for _, expression := range expressions {
+		rows.columns = append(rows.columns, strings.TrimSpace(expression))
+	}
+	row := func(schema string, size int64, found bool) []driver.Value {
+		values := make([]driver.Value, len(expressions))
+		for i, expression := range expressions {
+			switch {
+			case strings.EqualFold(strings.TrimSpace(expression), "table_schema"):
+				if found {
+					values[i] = schema
+				}
+			case found:
+				values[i] = size
+			case strings.Contains(strings.ToUpper(expression), "COALESCE("):
+				values[i] = int64(0)
+			}
+		}
+		return values
+	}
+
+	s := c.server
+	var databases []string
+	for db := range s.databases {
+		if database == "" || db == database {
+			databases = append(databases, db)
+		}
+	}
+	sort.Strings(databases)
+	var total int64
+	found := false
+	for _, db := range databases {
+		var size int64
+		tables := 0
+		for tableName, t := range s.databases[db].tables {
+			if name == "" || tableName == name {
+				size += t.size()
+				tables++
+			}
+		}
+		if tables == 0 {
+			continue
+		}
+		if group {
+			rows.values = append(rows.values, row(db, size, true))
+		}
+		total += size
+		found = true
+	}
+	if !group {
+		rows.values = append(rows.values, row(database, total, found))
+	}
+	return rows
+}
+
+func (t *table) size() int64 {
+	var size int64
+	for _, row := range t.rows {
+		for _, value := range row {
+			switch v := value.(type) {
+			case string:
+				size += int64(len(v))
+			case int64:
+				size += 4
+			}
+		}
+	}
+	return size
+}
+
+// interpolate replaces the placeholders of the query with the arguments
+func interpolate(query string, args []driver.NamedValue) (string, error) {
+	if len(args) == 0 {
+		return query, nil
+	}
+	var b strings.Builder
+	var quote byte
+	next := 0
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && i+1 < len(query) {
+				b.WriteByte(ch)
+				i++
+				ch = query[i]
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?':
+			if next == len(args) {
+				return "", fmt.Errorf("fakemysql: missing argument %d of %q", next+1, query)
+			}
+			b.WriteString(literal(args[next].Value))
+			next++
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	if next != len(args) {
+		return "", fmt.Errorf("fakemysql: %d arguments for %d placeholders of %q", len(args), next, query)
+	}
+	return b.String(), nil
+}
+
+// literal returns the value as a SQL literal
+func literal(value driver.Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+	case []byte:
+		return literal(string(v))
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseTuples parses the tuples of values of an INSERT statement, i.e. (1,'a'),(2,'b')
+func parseTuples(s string) ([][]interface{}, error) {
+	p := &parser{s: s}
+	var tuples [][]interface{}
+	for {
+		if !p.consume('(') {
+			return nil, p.syntaxError()
+		}
+		var tuple []interface{}
+		for !p.consume(')') {
+			if len(tuple) > 0 && !p.consume(',') {
+				return nil, p.syntaxError()
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			tuple = append(tuple, value)
+		}
+		tuples = append(tuples, tuple)
+		if p.skipSpace(); p.i == len(p.s) {
+			return tuples, nil
+		}
+		if !p.consume(',') {
+			return nil, p.syntaxError()
+		}
+	}
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) skipSpace() {
+	for p.i < len(p.s) && strings.ContainsRune(" \t\r\n", rune(p.s[p.i])) {
+		p.i++
+	}
+}
+
+// consume skips the next character if it is ch
+func (p *parser) consume(ch byte) bool {
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == ch {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) syntaxError() error {
+	return mysqlError(ErrSyntax, "You have an error in your SQL syntax near '%s'", p.s[p.i:])
+}
+
+// value parses a string, a number or NULL
+func (p *parser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.i == len(p.s) {
+		return nil, p.syntaxError()
+	}
+	if quote := p.s[p.i]; quote == '\'' || quote == '"' {
+		return p.string(quote)
+	}
+	start := p.i
+	for p.i < len(p.s) && !strings.ContainsRune(",) \t\r\n", rune(p.s[p.i])) {
+		p.i++
+	}
+	token := p.s[start:p.i]
+	if strings.EqualFold(token, "NULL") {
+		return nil, nil
+	}
+	if n, err := strconv.ParseInt(token, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f, nil
+	}
+	p.i = start
+	return nil, p.syntaxError()
+}
+
+// string parses a quoted string with the escape sequences of MySQL
+func (p *parser) string(quote byte) (string, error) {
+	var b strings.Builder
+	for p.i++; p.i < len(p.s); p.i++ {
+		ch := p.s[p.i]
+		switch {
+		case ch == '\\' && p.i+1 < len(p.s):
+			p.i++
+			switch e := p.s[p.i]; e {
+			case '0':
+				b.WriteByte(0)
+			case 'b':
+				b.WriteByte('\b')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'Z':
+				b.WriteByte('\x1a')
+			default:
+				b.WriteByte(e)
+			}
+		case ch == quote && p.i+1 < len(p.s) && p.s[p.i+1] == quote:
+			b.WriteByte(quote)
+			p.i++
+		case ch == quote:
+			p.i++
+			return b.String(), nil
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return "", p.syntaxError()
+}
+
+// splitList splits a comma separated list outside of parentheses and quotes
+func splitList(s string) []string {
+	var items []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// unquote returns the name of a quoted identifier
+func unquote(name string) string {
+	if len(name) >= 2 && name[0] == '`' && name[len(name)-1] == '`' {
+		return strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+	}
+	return name
+}
+
+// unquoteString returns the value of a quoted string, or an empty string if there is none
+func unquoteString(s string) string {
+	if s == "" {
+		return ""
+	}
+	value, err := (&parser{s: s}).string(s[0])
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
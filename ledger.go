@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ledger entry kinds
+const (
+	LedgerInsert = "I"
+	LedgerDelete = "D"
+
+	// number of ids checked by a single query of check-ledger
+	ledgerCheckBatch = 1000
+)
+
+// ledger is an append-only file that records the primary key of every row whose
+// insertion has been acknowledged by the server. Rows deleted by the workload are
+// recorded too so that they are not reported as lost. Each line has the format
+// "<I|D> <table> <id>".
+type ledger struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// writeLedger is the ledger of the current run. It is nil when --ledger is not provided.
+var writeLedger *ledger
+
+// openLedger opens the ledger for appending. A truncated ledger starts empty,
+// i.e. when the database has been dropped with --overwrite, so that the rows of
+// the dropped database are not reported as missing.
+func openLedger(path string, truncate bool) (*ledger, error) {
+	flags := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	if truncate {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger %q. Reason: %v", path, err)
+	}
+	l := &ledger{
+		file: file,
+		w:    bufio.NewWriter(file),
+		done: make(chan struct{}),
+	}
+
+	// flush the ledger to the disk periodically
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+				if err := l.flush(); err != nil {
+					out.error(err, "Failed to flush the ledger")
+				}
+			}
+		}
+	}()
+	return l, nil
+}
+
+// record appends an entry to the ledger. It must be called only after the server
+// has acknowledged the statement.
+func (l *ledger) record(kind, table string, id int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "%s %s %d\n", kind, table, id)
+}
+
+func (l *ledger) flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// close flushes the pending entries and closes the ledger file
+func (l *ledger) close() error {
+	if l == nil {
+		return nil
+	}
+	close(l.done)
+	l.wg.Wait()
+	if err := l.flush(); err != nil {
+		return err
+	}
+	return l.file.Close()
+}
+
+// readLedger returns the ids of the rows that are expected to exist per table
+func readLedger(path string) (map[string]map[int64]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows := map[string]map[int64]bool{}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// the last line may be incomplete if the generator has been killed while writing it
+		if len(fields) != 3 {
+			out.info("Skipping malformed ledger entry at line %d: %q", line, scanner.Text())
+			continue
+		}
+		id, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			out.info("Skipping malformed ledger entry at line %d: %q", line, scanner.Text())
+			continue
+		}
+		table := fields[1]
+		if rows[table] == nil {
+			rows[table] = map[int64]bool{}
+		}
+		switch fields[0] {
+		case LedgerInsert:
+			rows[table][id] = true
+		case LedgerDelete:
+			delete(rows[table], id)
+		default:
+			out.info("Skipping malformed ledger entry at line %d: %q", line, scanner.Text())
+		}
+	}
+	return rows, scanner.Err()
+}
+
+// checkLedger confirms that every row recorded in the ledger exists in the database
+func (opt *GeneratorOptions) checkLedger() error {
+	if opt.ledger == "" {
+		return fmt.Errorf("no ledger has been provided. Use --ledger to provide the ledger file")
+	}
+	expected, err := readLedger(opt.ledger)
+	if err != nil {
+		return fmt.Errorf("failed to read ledger %q. Reason: %v", opt.ledger, err)
+	}
+
+	db, err = opt.getClient(opt.dbName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	out.phase("checking-ledger", "Checking ledger %q against database %q.....", opt.ledger, opt.dbName)
+	tables := make([]string, 0, len(expected))
+	for table := range expected {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var totalExpected, totalMissing int
+	for _, table := range tables {
+		ids := make([]int64, 0, len(expected[table]))
+		for id := range expected[table] {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		missing, err := findMissingRows(table, ids)
+		if err != nil {
+			return err
+		}
+		totalExpected += len(ids)
+		totalMissing += len(missing)
+		if out.json {
+			out.emit(EventLedger, event{"table": table, "expected": len(ids), "missing": len(missing), "missingIds": missing})
+			continue
+		}
+		out.printf("%20s: %d rows expected, %d rows missing\n", table, len(ids), len(missing))
+		if len(missing) > 0 {
+			out.printf("%20s  missing ids: %s\n", "", formatIDs(missing, 100))
+		}
+	}
+
+	if totalMissing > 0 {
+		return fmt.Errorf("%d of %d acknowledged rows are missing in database %q", totalMissing, totalExpected, opt.dbName)
+	}
+	out.info("All %d acknowledged rows exist in database %q", totalExpected, opt.dbName)
+	return nil
+}
+
+// findMissingRows returns the ids that do not exist in the table
+func findMissingRows(table string, ids []int64) ([]int64, error) {
+	var missing []int64
+	for start := 0; start < len(ids); start += ledgerCheckBatch {
+		end := start + ledgerCheckBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		rows, err := db.Query(fmt.Sprintf("SELECT id FROM %s WHERE id IN (%s)", table, formatIDs(batch, 0)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check rows of table %q. Reason: %v", table, err)
+		}
+		found := map[int64]bool{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			found[id] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range batch {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// formatIDs returns the ids as a comma separated list. If limit is positive, only
+// the first limit ids are included.
+func formatIDs(ids []int64, limit int) string {
+	parts := make([]string, 0, len(ids))
+	for i, id := range ids {
+		if limit > 0 && i == limit {
+			parts = append(parts, fmt.Sprintf("... (%d more)", len(ids)-limit))
+			break
+		}
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
@@ -2,39 +2,100 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/go-sql-driver/mysql"
-	"io/ioutil"
-	"log"
+	"github.com/hossainemruz/mysql-data-generator/generator"
 	"math"
 	"math/rand"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 type GeneratorOptions struct {
-	size        string
-	host        string
-	port        int
-	user        string
-	password    string
-	caCert      string
-	clientCert  string
-	clientKey   string
-	requireTLS  bool
-	concurrency int
-	tableNumber int
-	dbName      string
-	overwrite   bool
+	size           string
+	host           string
+	port           int
+	user           string
+	password       string
+	passwordFile   string
+	config         string
+	profile        string
+	secretDir      string
+	defaultsFile   string
+	caCert         string
+	clientCert     string
+	clientKey      string
+	requireTLS     bool
+	tlsModeName    string
+	tlsServerName  string
+	concurrency    int
+	tableNumber    int
+	dbName         string
+	overwrite      bool
+	dryRun         bool
+	outputFile     string
+	duration       time.Duration
+	rate           string
+	mode           string
+	workloadMix    string
+	reportInterval time.Duration
+	metricsAddr    string
+	output         string
+	maxErrors      int
+	maxRetries     int
+	retryBackoff   time.Duration
+
+	outageTolerance time.Duration
+	ledger          string
+
+	txnRows            int
+	isolationLevel     string
+	multiTableTxn      bool
+	multiTableTxnRatio float64
+
+	dsn               string
+	socket            string
+	charset           string
+	collation         string
+	connAttributes    string
+	connectTimeout    time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	interpolateParams bool
+	serverPubKey      string
+
+	checkpoint         string
+	checkpointInterval time.Duration
+	resume             bool
+	seed               int64
+
+	exportFormat      string
+	exportDir         string
+	exportCompression string
+	chunkSize         string
+	csvDelimiter      string
+	csvQuote          string
+	csvNull           string
+	csvLineTerminator string
+	csvHeader         bool
+
+	binlogFile         string
+	binlogTransactions int64
+	binlogServerUUID   string
+
+	replicaHost    string
+	stallThreshold time.Duration
+	stressTxnSizes string
+	stressColumns  int
 }
 
 const (
@@ -42,148 +103,446 @@ const (
 	OneMB = 1024 * 1024
 	OneGB = 1024 * 1024 * 1024
 
-	// certs path
-	ca = "/mysql/certs/ca.crt"
-	clientCerts = "/mysql/certs/client.crt"
-	clientKey   = "/mysql/certs/client.key"
+	// modes
+	ModeGenerate = "generate"
+	ModeWorkload = "workload"
+	ModeStress   = "stress"
 )
 
 var opt = GeneratorOptions{}
 
 var db *sql.DB
 
+// limiter throttles the insert workers when --rate is provided
+var limiter *tokenBucket
+
 func main() {
-	flag.Parse()
+	cmd, args, err := parseCommand(os.Args[1:])
+	if err != nil {
+		out.error(err, "Invalid command")
+		usage()
+		os.Exit(2)
+	}
+	_ = flag.CommandLine.Parse(args)
 	rand.Seed(time.Now().UnixNano())
 
-	if opt.user == "" {
-		opt.user = os.Getenv("USERNAME")
-	}
-	if opt.password == "" {
-		opt.password = os.Getenv("PASSWORD")
+	if err := loadConfig(); err != nil {
+		out.error(err, "Failed to load the config")
+		os.Exit(1)
 	}
 
-	if opt.caCert == "" {
-		opt.caCert = os.Getenv("CA_CERT")
+	if err := out.setFormat(opt.output); err != nil {
+		out.error(err, "Invalid output format")
+		os.Exit(1)
 	}
 
-	if opt.clientCert == "" {
-		opt.clientCert = os.Getenv("CLIENT_CERT")
+	if err := opt.loadCredentials(); err != nil {
+		out.error(err, "Failed to load the credentials")
+		os.Exit(1)
 	}
 
-	if opt.clientKey == "" {
-		opt.clientKey = os.Getenv("CLIENT_KEY")
+	if opt.metricsAddr != "" {
+		serveMetrics(opt.metricsAddr)
 	}
 
-	err := opt.generateData()
+	// without a command, the command is chosen by --mode
+	if cmd == nil {
+		cmd, err = modeCommand(opt.mode)
+	}
+	if err == nil {
+		err = cmd.run(&opt)
+	}
+	var interrupted *interruptedError
+	if errors.As(err, &interrupted) {
+		out.info("Generator has been %s", interrupted.Error())
+		os.Exit(interrupted.exitCode())
+	}
 	if err != nil {
-		panic(err)
+		out.error(err, "Failed to run the generator")
+		os.Exit(1)
 	}
 }
 
 func init() {
+	flag.Usage = usage
+	flag.StringVar(&opt.config, "config", "", "YAML or TOML (.toml) file to read the options from. Options set on the command line take precedence")
+	flag.StringVar(&opt.profile, "profile", "", "Named profile of the options to use. Either a builtin profile (small-smoke, 5gb-backup-test) or a profile of --config")
 	flag.StringVar(&opt.size, "size", "128MB", "Size of the desired database")
 	flag.StringVar(&opt.host, "host", "localhost", "MySQL host address")
 	flag.IntVar(&opt.port, "port", 3306, "Port number where the MySQL is listening")
 	flag.StringVar(&opt.user, "user", "", "Username to use to connect with the database")
 	flag.StringVar(&opt.password, "password", "", "Password to use to connect with the database")
+	flag.StringVar(&opt.passwordFile, "password-file", "", "File containing the password to use to connect with the database")
+	flag.StringVar(&opt.secretDir, "secret-dir", "", "Directory of a mounted Kubernetes secret with the username and password keys")
+	flag.StringVar(&opt.defaultsFile, "defaults-file", "", "MySQL option file (i.e. ~/.my.cnf) to read the user, password, host, port and socket from. The [client] and [mysql-data-generator] groups are read")
 	flag.StringVar(&opt.dbName, "database", "sampleData", "Name of the database to create")
 	flag.IntVar(&opt.concurrency, "concurrency", 1, "Number of parallel thread to inject data")
 	flag.IntVar(&opt.tableNumber, "tables", 1, "Number of tables to insert in the database")
-	flag.BoolVar(&opt.overwrite, "overwrite", false, "Drop previous database/table (if they exist) before inserting new one.")
-	flag.StringVar(&opt.caCert, "ca-cert", "", "Certificates authority(CA) file is used to contains a list of trusted SSL CAs")
-	flag.StringVar(&opt.clientCert, "client-cert", "", "Server public key certificate file is used to connect encrypted connections")
-	flag.StringVar(&opt.clientKey, "ca-key", "", "Server private key certificate file is used to connect encrypted connections")
-	flag.BoolVar(&opt.requireTLS, "require-tls", false, "Require-tls is used to client connection is mandatory or not")
+	flag.BoolVar(&opt.overwrite, "overwrite", false, "Drop previous database/table (if they exist) before inserting new one. The SQL dump, the schema.sql of the delimited export and the binlog start by dropping the database.")
+	flag.StringVar(&opt.outputFile, "output-file", "", "Write the statements to this SQL dump file (.sql, .sql.gz or .sql.zst) instead of executing them on a server")
+	flag.StringVar(&opt.exportFormat, "export-format", "", "Write the rows to files in --export-dir instead of inserting them into a server. One of (csv, tsv, shell). csv and tsv write one delimited file per table, shell writes a MySQL Shell dump for util.loadDump")
+	flag.StringVar(&opt.exportDir, "export-dir", "", "Directory of the files written by --export-format")
+	flag.StringVar(&opt.chunkSize, "chunk-size", "64MB", "Uncompressed size of the data files of each table written by --export-format=shell")
+	flag.StringVar(&opt.exportCompression, "export-compression", CompressionZstd, "Compression of the data files written by --export-format=shell. One of (zstd, gzip, none)")
+	flag.StringVar(&opt.csvDelimiter, "csv-delimiter", "", "Single character field delimiter of the exported files (default \",\" for csv, \"\\t\" for tsv)")
+	flag.StringVar(&opt.csvQuote, "csv-quote", "", "Character enclosing the text fields of the exported files. Set empty to disable quoting (default \"\\\"\" for csv, none for tsv)")
+	flag.StringVar(&opt.csvNull, "csv-null", "", "Marker of the NULL values in the exported files (default \"\\N\")")
+	flag.StringVar(&opt.csvLineTerminator, "csv-line-terminator", "", "Line terminator of the exported files (default \"\\n\")")
+	flag.BoolVar(&opt.csvHeader, "csv-header", false, "Write the column names as the first line of the exported files")
+	flag.StringVar(&opt.binlogFile, "binlog-file", "", "Write the row based binary log events of the run to this file instead of executing the statements on a server")
+	flag.Int64Var(&opt.binlogTransactions, "binlog-transactions", 10000, "Number of write transactions of the workload command written to --binlog-file after the inserts of --size")
+	flag.StringVar(&opt.binlogServerUUID, "binlog-server-uuid", defaultBinlogServerUUID, "Server UUID of the GTIDs written to --binlog-file")
+	flag.BoolVar(&opt.dryRun, "dry-run", false, "Print the estimated rows, disk usage, binary log volume and duration of the run without creating anything")
+	flag.StringVar(&opt.caCert, "ca-cert", "", "Certificates authority(CA) file used to verify the server certificate. Read from the CA_CERT environment variable if not provided")
+	flag.StringVar(&opt.clientCert, "client-cert", "", "Client certificate file for encrypted connections. Read from the CLIENT_CERT environment variable if not provided")
+	flag.StringVar(&opt.clientKey, "client-key", "", "Client private key file for encrypted connections. Read from the CLIENT_KEY environment variable if not provided")
+	flag.StringVar(&opt.clientKey, "ca-key", "", "Deprecated: use --client-key")
+	flag.BoolVar(&opt.requireTLS, "require-tls", false, "Require TLS connections. Same as --tls-mode=verify-identity")
+	flag.StringVar(&opt.tlsModeName, "tls-mode", "", "TLS mode of the connections. One of (disabled, preferred, required, verify-ca, verify-identity) (default \"disabled\", or the tls parameter of --dsn)")
+	flag.StringVar(&opt.tlsServerName, "tls-server-name", "", "Server name used for SNI and, in verify-identity mode, to verify the server certificate. Defaults to the host")
+	flag.StringVar(&opt.dsn, "dsn", "", "Data source name of the server (i.e. user:pass@tcp(host:3306)/?timeout=5s). The database of the DSN is ignored. Overrides --host and --port unless they are set explicitly")
+	flag.StringVar(&opt.socket, "socket", "", "Unix socket file to connect to instead of --host and --port")
+	flag.StringVar(&opt.charset, "charset", "", "Character set of the connections (i.e. utf8mb4)")
+	flag.StringVar(&opt.collation, "collation", "", "Collation of the connections (i.e. utf8mb4_general_ci)")
+	flag.StringVar(&opt.connAttributes, "connection-attributes", defaultConnAttributes, "Connection attributes sent to the server as comma separated key:value pairs, which are shown in performance_schema.session_connect_attrs")
+	flag.DurationVar(&opt.connectTimeout, "connect-timeout", 0, "Timeout for establishing a connection")
+	flag.DurationVar(&opt.readTimeout, "read-timeout", 0, "I/O read timeout of the connections")
+	flag.DurationVar(&opt.writeTimeout, "write-timeout", 0, "I/O write timeout of the connections")
+	flag.BoolVar(&opt.interpolateParams, "interpolate-params", false, "Interpolate the placeholders into the statements on the client instead of preparing them on the server")
+	flag.StringVar(&opt.serverPubKey, "server-public-key", "", "RSA public key file of the server for caching_sha2_password authentication over unencrypted connections")
+	flag.DurationVar(&opt.duration, "duration", 0, "Stop inserting data after this duration (i.e. 30m). If --size is not provided explicitly, the size limit is ignored")
+	flag.StringVar(&opt.rate, "rate", "", "Throttle the insertion to a fixed rate shared by all the workers (i.e. 500rows/s or 5MB/s)")
+	flag.StringVar(&opt.mode, "mode", ModeGenerate, "Command to run when no command is given. One of (generate, workload, stress). Prefer the commands over this flag")
+	flag.StringVar(&opt.workloadMix, "workload-mix", defaultWorkloadMix, "Weights of the operations performed in workload mode (operations: select, range, update, delete, insert)")
+	flag.DurationVar(&opt.reportInterval, "report-interval", 10*time.Second, "Interval of printing the latency and throughput statistics. Set 0 to print them only at the end")
+	flag.StringVar(&opt.metricsAddr, "metrics-addr", "", "Address to serve the Prometheus metrics at (i.e. :9090). Metrics are not served if empty")
+	flag.StringVar(&opt.output, "output", OutputText, "Output format. One of (text, json). In json format, each event is written as a single line json object")
+	flag.IntVar(&opt.maxErrors, "max-errors", 0, "Abort the run when the number of failed statements exceeds this budget. Set 0 for no limit")
+	flag.IntVar(&opt.maxRetries, "max-retries", 5, "Number of times a statement failed with a transient error (deadlock, lock wait timeout, connection lost) is retried")
+	flag.DurationVar(&opt.retryBackoff, "retry-backoff", 100*time.Millisecond, "Initial delay before retrying a failed statement. The delay is doubled on every retry")
+	flag.StringVar(&opt.ledger, "ledger", "", "Append the primary key of every acknowledged insert to this file. Use the verify command to confirm that the rows exist later")
+	flag.IntVar(&opt.txnRows, "txn-rows", 0, "Number of rows inserted by each transaction. Set 0 to insert the rows in autocommit mode")
+	flag.StringVar(&opt.isolationLevel, "isolation-level", "", "Isolation level of the insert transactions. One of (read-uncommitted, read-committed, repeatable-read, serializable). Server default if empty")
+	flag.BoolVar(&opt.multiTableTxn, "multi-table-txn", false, "Deprecated: use --multi-table-txn-ratio=1. Make every transaction a multi-table transaction")
+	flag.Float64Var(&opt.multiTableTxnRatio, "multi-table-txn-ratio", 0, "Fraction of the transactions (0 to 1) that insert a parent row followed by its child rows in the other tables. The rest insert all the rows into a single table. Requires --txn-rows of at least 2")
+	flag.StringVar(&opt.checkpoint, "checkpoint", "", "Persist the progress to this file, or to the "+checkpointTableName+" table of the target database if set to \"table\"")
+	flag.DurationVar(&opt.checkpointInterval, "checkpoint-interval", 10*time.Second, "Interval of persisting the progress to the checkpoint")
+	flag.BoolVar(&opt.resume, "resume", false, "Continue the interrupted run from the checkpoint")
+	flag.Int64Var(&opt.seed, "seed", 0, "Seed of the generated data. Runs with the same seed and concurrency generate the same rows. Random if 0")
+	flag.StringVar(&opt.replicaHost, "replica-host", "", "Replica host address to check whether the rows of the stress profile have been replicated")
+	flag.DurationVar(&opt.stallThreshold, "stall-threshold", 30*time.Second, "A stress step that takes longer than this on the server or the replica is reported as stalled")
+	flag.StringVar(&opt.stressTxnSizes, "stress-txn-sizes", "64MB,256MB,512MB", "Comma separated sizes of the huge transactions of the stress profile")
+	flag.IntVar(&opt.stressColumns, "stress-columns", 200, "Number of wide columns of the table used by the stress profile")
+	flag.DurationVar(&opt.outageTolerance, "outage-tolerance", 0, "Keep retrying the writes that fail due to lost connections or a read-only endpoint (i.e. during failover) for up to this duration. Set 0 to disable")
+}
+
+// isFlagSet returns true if the flag has been provided explicitly in the command line
+func isFlagSet(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
 }
 
 func (opt *GeneratorOptions) generateData() error {
 	startingTime := time.Now()
 
-	if err := opt.storeCerts(); err != nil {
-		return err
+	if opt.resume && opt.checkpoint == "" {
+		return fmt.Errorf("--resume requires --checkpoint")
+	}
+	if opt.resume && opt.overwrite {
+		return fmt.Errorf("--resume can not be used with --overwrite as it drops the data of the interrupted run")
 	}
 
-	// create the database if it does not exist
-	err := opt.ensureDatabase()
+	if opt.dryRun {
+		return opt.planRun()
+	}
+	if opt.outputFile != "" {
+		return opt.writeDump()
+	}
+	if opt.binlogFile != "" {
+		return opt.writeBinlog(nil)
+	}
+	if opt.exportFormat == ExportShell {
+		if opt.overwrite {
+			return fmt.Errorf("--overwrite can not be used with --export-format=shell as util.loadDump does not drop the existing schemas")
+		}
+		return opt.writeShellDump()
+	}
+	if opt.exportFormat != "" {
+		return opt.writeExport()
+	}
+
+	err := opt.prepareDatabase()
 	if err != nil {
 		return err
 	}
-	db, err = opt.getClient(opt.dbName)
+	defer db.Close()
+	if err := opt.ensureCheckpointTable(); err != nil {
+		return err
+	}
+
+	// parse desired data size. when the run is time bounded and no size has been
+	// provided explicitly, keep inserting until the duration has elapsed.
+	desiredAmount := 0
+	if opt.duration == 0 || isFlagSet("size") {
+		desiredAmount, err = opt.parseSize()
+		if err != nil {
+			return err
+		}
+	}
+
+	// parse the desired insertion rate
+	if opt.rate != "" {
+		limiter, err = parseRate(opt.rate)
+		if err != nil {
+			return err
+		}
+	}
+
+	isolationLevel, err := parseIsolationLevel(opt.isolationLevel)
 	if err != nil {
 		return err
 	}
-	maxConnection := int(math.Max(140, float64(opt.concurrency+10)))
-	db.SetConnMaxLifetime(24 * time.Hour)
-	db.SetMaxOpenConns(maxConnection)
-	db.SetMaxIdleConns(maxConnection)
-	//defer db.Close()
+	opt.parseMultiTableTxn()
 
-	// create tables
-	for i := 0; i < opt.tableNumber; i++ {
-		tableName := fmt.Sprintf("table%d", i)
-		statement := fmt.Sprintf("CREATE TABLE %s (id int NOT NULL AUTO_INCREMENT PRIMARY KEY,name text, height int, weight int, age int,description Text)", tableName)
-		if _, err = db.Exec(statement); err != nil {
-			if !strings.Contains(err.Error(), "already exists") {
-				return fmt.Errorf("failed to crate table %q. Reason: %v\n", tableName, err)
+	if opt.ledger != "" {
+		writeLedger, err = openLedger(opt.ledger, opt.overwrite)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeLedger.close(); err != nil {
+				out.error(err, "Failed to close the ledger")
 			}
-			fmt.Println("Table already exist")
+		}()
+	}
+
+	// continue from the checkpoint of the interrupted run, if any
+	var cp *checkpoint
+	if opt.resume {
+		cp, err = opt.loadCheckpoint()
+		if err != nil {
+			return err
+		}
+		switch {
+		case cp == nil:
+			out.info("No checkpoint found. Starting a new run")
+		case cp.Completed:
+			out.info("The checkpointed run has already been completed. Nothing to resume")
+			return nil
+		default:
+			out.info("Resuming from checkpoint: %d rows, %s generated in %s", cp.RowsGenerated, formatSize(int(cp.BytesGenerated)), time.Duration(cp.ElapsedSeconds*float64(time.Second)).Round(time.Second).String())
 		}
 	}
 
-	// parse desired data size
-	desiredAmount, err := opt.parseSize()
+	out.phase("generating", "Generating sample data......................")
+	sink := generator.NewMySQLSink(db)
+	sink.Isolation = isolationLevel
+	curSize, err := opt.databaseSize(sink)
 	if err != nil {
 		return err
 	}
+	metrics.setDatabaseSize(curSize)
+	initialSize := curSize
+	seed := opt.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	var resume []int64
+	if cp != nil {
+		// measure the progress from the size before the interrupted run started,
+		// otherwise the resumed run would overshoot the desired amount
+		initialSize = cp.InitialSize
+		seed = cp.Seed
+		resume = cp.Workers
+	}
+	progress.init(cp, opt.dbName, seed, initialSize, opt.concurrency)
+
+	// a resumed run only generates the data and spends the time that are left
+	var size int64
+	if desiredAmount > 0 {
+		size = int64(desiredAmount - (curSize - initialSize))
+	}
+	var duration time.Duration
+	if opt.duration > 0 {
+		duration = opt.duration - progress.elapsed()
+	}
+	if (desiredAmount > 0 && size <= 0) || (opt.duration > 0 && duration <= 0) {
+		out.info("The checkpointed run has already reached its target. Nothing to resume")
+		return opt.saveCheckpoint(true)
+	}
 
-	fmt.Println("Generating sample data......................")
-	initialSize, err := opt.getDatabaseSize()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shutdown := notifyShutdown(cancel)
+	defer shutdown.stop()
+	budget := newErrorBudget(opt.maxErrors, cancel)
+	collector := newStatsCollector()
+	recorders := make([]*statsRecorder, opt.concurrency)
+	for i := range recorders {
+		recorders[i] = collector.newRecorder()
+	}
+	// consecutive failed batches of each worker
+	failures := make([]int, opt.concurrency)
+	genOpts := generator.Options{
+		Database:        opt.dbName,
+		Tables:          opt.tableNumber,
+		Size:            size,
+		Duration:        duration,
+		Concurrency:     opt.concurrency,
+		TxnRows:         opt.txnRows,
+		MultiTableRatio: opt.multiTableTxnRatio,
+		Seed:            seed,
+		Resume:          resume,
+		// the ledger records the ids of the inserted rows instead
+		DiscardIDs: true,
+		OnProgress: opt.reportProgress(sink, initialSize, desiredAmount),
+		Retry: func(ctx context.Context, write func() error) error {
+			return opt.execWithRetry(ctx, true, write)
+		},
+		OnBatch: func(batch generator.Batch) {
+			op, table := batchStats(batch)
+			recorders[batch.Worker].record(op, table, batch.Latency, batch.Rows, batch.Bytes, nil)
+			failures[batch.Worker] = 0
+			progress.commit(batch)
+			for _, t := range batch.Tables {
+				for _, row := range t.Rows {
+					writeLedger.record(LedgerInsert, t.Table, row.ID)
+				}
+			}
+		},
+		OnError: func(batch generator.Batch, err error) error {
+			op, table := batchStats(batch)
+			recorders[batch.Worker].record(op, table, batch.Latency, batch.Rows, batch.Bytes, err)
+			out.error(err, "Failed to insert the batch")
+			if err := budget.add(err); err != nil {
+				return err
+			}
+			failures[batch.Worker]++
+			opt.failureBackoff(ctx, failures[batch.Worker])
+			return nil
+		},
+	}
+	if limiter != nil {
+		genOpts.Throttle = func(ctx context.Context, batch generator.Batch) error {
+			return limiter.wait(ctx, limiter.batchCost(batch.Rows, batch.Bytes))
+		}
+	}
+	gen, err := generator.NewWithSink(sink, genOpts)
 	if err != nil {
 		return err
 	}
 
-	// start go routines to insert data in parallel
-	ctx, cancel := context.WithCancel(context.Background())
+	// persist the progress and report latency and throughput statistics
+	// periodically while the data is generated
+	background, stopBackground := context.WithCancel(ctx)
 	wg := sync.WaitGroup{}
-	for i := 0; i < opt.concurrency; i++ {
+	if opt.checkpoint != "" {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := opt.insertRows(ctx)
-			if err != nil {
-				fmt.Println("Err: ", err)
-			}
+			opt.checkpointPeriodically(background)
 		}()
 	}
-
-	// monitor progress and stop data insertion when 100% completed
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		defer func() {
-			fmt.Println("Stopping data insertion...")
-			cancel()
-		}()
-		opt.monitorProgress(initialSize, desiredAmount)
+		collector.reportStats(background, opt.reportInterval)
 	}()
+
+	for i := 0; i < opt.concurrency; i++ {
+		metrics.workerStarted()
+	}
+	_, err = gen.Generate(ctx)
+	for i := 0; i < opt.concurrency; i++ {
+		metrics.workerStopped()
+	}
+	stopBackground()
 	wg.Wait()
 
+	interrupted := shutdown.interrupted()
+	if interrupted != nil && errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	if err := opt.saveCheckpoint(err == nil && interrupted == nil); err != nil {
+		out.error(err, "Failed to save the checkpoint")
+	}
+	if err != nil {
+		out.error(err, "Aborting the run")
+		return err
+	}
+
 	// show final statistics
-	fmt.Println("Successfully inserted demo data....")
+	if interrupted == nil {
+		out.phase("completed", "Successfully inserted demo data....")
+	}
 	totalTime := time.Since(startingTime)
-	curSize, err := opt.getDatabaseSize()
+	curSize, err = opt.databaseSize(sink)
+	if err != nil {
+		return err
+	}
+
+	dataInserted := curSize - initialSize
+	speed := dataInserted / int(math.Max(1, totalTime.Seconds()))
+	out.text("\n=========================== Summery ===========================\n")
+	out.summary([]summaryField{
+		{key: "bytesInserted", title: "Total data inserted", text: formatSize(dataInserted), value: dataInserted},
+		{key: "rowsInserted", title: "Total rows inserted", text: strconv.FormatInt(atomic.LoadInt64(&metrics.rowsInserted), 10), value: atomic.LoadInt64(&metrics.rowsInserted)},
+		{key: "durationSeconds", title: "Total time taken", text: totalTime.String(), value: totalTime.Seconds()},
+		{key: "bytesPerSecond", title: "Speed", text: formatSize(speed) + "/s", value: speed},
+		{key: "failedStatements", title: "Failed statements", text: strconv.FormatInt(budget.failed(), 10), value: budget.failed()},
+	})
+	out.text("\n")
+	stats, elapsed := collector.summary()
+	printStats(StatsScopeTotal, stats, elapsed)
+	if opt.outageTolerance > 0 || outages.observed() {
+		outages.report()
+	}
+
+	out.text("\n====================== Current Database Sizes =================\n")
+	if err := opt.showDBSizes(); err != nil {
+		return err
+	}
+	return interrupted
+}
+
+// prepareDatabase creates the database and the tables if they do not exist and
+// initializes the shared database client.
+func (opt *GeneratorOptions) prepareDatabase() error {
+	// create the database if it does not exist
+	err := opt.ensureDatabase()
+	if err != nil {
+		return err
+	}
+	db, err = opt.getClient(opt.dbName)
 	if err != nil {
 		return err
 	}
+	maxConnection := opt.maxConnections()
+	db.SetConnMaxLifetime(24 * time.Hour)
+	db.SetMaxOpenConns(maxConnection)
+	db.SetMaxIdleConns(maxConnection)
 
-	fmt.Println("\n=========================== Summery ===========================")
-	fmt.Printf("%35s: %s\n", "Total data inserted", formatSize(curSize-initialSize))
-	fmt.Printf("%35s: %s\n", "Total time taken", totalTime.String())
-	fmt.Printf("%35s: %s/s\n", "Speed", formatSize((curSize-initialSize)/int(totalTime.Seconds())))
+	// create tables
+	for i := 0; i < opt.tableNumber; i++ {
+		tableName := fmt.Sprintf("table%d", i)
+		if _, err = db.Exec(generator.CreateTableStatement(tableName)); err != nil {
+			if !strings.Contains(err.Error(), "already exists") {
+				return fmt.Errorf("failed to crate table %q. Reason: %v\n", tableName, err)
+			}
+			out.info("Table already exist")
+		}
+	}
+	return nil
+}
 
-	fmt.Println("\n====================== Current Database Sizes =================")
-	return opt.showDBSizes()
+// maxConnections returns the size of the connection pool
+func (opt *GeneratorOptions) maxConnections() int {
+	return int(math.Max(140, float64(opt.concurrency+10)))
 }
 
 func (opt *GeneratorOptions) ensureDatabase() error {
@@ -199,59 +558,32 @@ func (opt *GeneratorOptions) ensureDatabase() error {
 	//mydb.SetMaxIdleConns(120)
 
 	// ping database to check the connection
-	fmt.Println("Pinging the database.....")
+	out.phase("connecting", "Pinging the database.....")
 	if err := mydb.Ping(); err != nil {
 		return err
 	}
-	fmt.Println("Ping Succeeded")
+	out.info("Ping Succeeded")
 
 	if opt.overwrite {
-		fmt.Printf("Dropping database: %s\n", opt.dbName)
+		out.phase("dropping-database", "Dropping database: %s", opt.dbName)
 		if _, err := mydb.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s;", opt.dbName)); err != nil {
 			return err
 		}
 	}
 
 	// create the database
-	fmt.Printf("Creating database: %q.....\n", opt.dbName)
+	out.phase("creating-database", "Creating database: %q.....", opt.dbName)
 	if _, err = mydb.Exec(fmt.Sprintf("CREATE DATABASE %s;", opt.dbName)); err != nil {
 		if strings.Contains(err.Error(), "database exists") {
-			fmt.Println("Database already exist")
+			out.info("Database already exist")
 			return nil
 		}
 		return err
 	}
-	fmt.Printf("Database %q has been created successfully\n", opt.dbName)
+	out.info("Database %q has been created successfully", opt.dbName)
 	return nil
 }
 
-func (opt *GeneratorOptions) insertRows(ctx context.Context) error {
-	//db.SetConnMaxLifetime(2 * time.Hour)
-	//db.SetMaxOpenConns(opt.concurrency + 10)
-	//db.SetMaxIdleConns(120)
-
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-			tableName := fmt.Sprintf("table%d", rand.Int()%opt.tableNumber)
-			statement := fmt.Sprintf("INSERT INTO %s (name,height,weight,age,description) VALUES (%q,%d,%d,%d,%q)",
-				tableName,
-				generateName(),
-				120+rand.Int()%81,
-				30+rand.Int()%201,
-				10+rand.Int()%101,
-				loremIpsum,
-			)
-			_, err := db.Exec(statement)
-			if err != nil {
-				fmt.Printf("Failed to insert row into table: %s. Reason: %v.\n", tableName, err)
-			}
-		}
-	}
-}
-
 func (opt *GeneratorOptions) showDBSizes() error {
 	statement := fmt.Sprintf("SELECT table_schema, round(SUM(data_length + index_length)) FROM information_schema.TABLES GROUP BY table_schema")
 	rows, err := db.Query(statement)
@@ -261,6 +593,7 @@ func (opt *GeneratorOptions) showDBSizes() error {
 	defer rows.Close()
 
 	var dbname, size sql.RawBytes
+	sizes := event{}
 	for rows.Next() {
 		err := rows.Scan(&dbname, &size)
 		if err != nil {
@@ -270,9 +603,13 @@ func (opt *GeneratorOptions) showDBSizes() error {
 		if err != nil {
 			return err
 		}
-		fmt.Printf("%35s: %s\n", string(dbname), formatSize(sizeInByte))
+		sizes[string(dbname)] = sizeInByte
+		out.text("%35s: %s\n", string(dbname), formatSize(sizeInByte))
 	}
-	return nil
+	if out.json {
+		out.emit(EventDBSizes, event{"sizes": sizes})
+	}
+	return rows.Err()
 }
 
 func (opt *GeneratorOptions) showTableSize(tableName string) error {
@@ -283,7 +620,7 @@ func (opt *GeneratorOptions) showTableSize(tableName string) error {
 
 	rows, err := db.Query(statement)
 	if err != nil {
-		fmt.Println("failed to execute query. Reason: ", err)
+		out.error(err, "failed to execute query")
 		return err
 	}
 	defer rows.Close()
@@ -295,126 +632,87 @@ func (opt *GeneratorOptions) showTableSize(tableName string) error {
 		if err != nil {
 			return err
 		}
-		fmt.Printf("%20s: %15s\n", tableName, formatSize(sizeInByte))
+		if out.json {
+			out.emit(EventDBSizes, event{"sizes": event{tableName: sizeInByte}})
+			continue
+		}
+		out.printf("%20s: %15s\n", tableName, formatSize(sizeInByte))
 	}
 	return nil
 }
 
-func (opt *GeneratorOptions) monitorProgress(initialSize, desiredAmount int) {
-	fmt.Println("Current Database Size: ", formatSize(initialSize), " Desired Amount to Inject: ", formatSize(desiredAmount))
-	ticker := time.NewTicker(1 * time.Second)
+// reportProgress returns the progress callback of the generator. The progress
+// is measured from the size of the database before the run, initialSize. A
+// desiredAmount of zero means that the run is time bounded.
+func (opt *GeneratorOptions) reportProgress(sink generator.Measurer, initialSize, desiredAmount int) func(generator.Progress) {
+	if desiredAmount > 0 {
+		out.info("Current Database Size:  %s  Desired Amount to Inject:  %s", formatSize(initialSize), formatSize(desiredAmount))
+	} else {
+		out.info("Current Database Size:  %s  Duration:  %s", formatSize(initialSize), opt.duration.String())
+	}
 	previousSize := initialSize
-	for {
-		select {
-		case <-ticker.C:
-			curSize, err := opt.getDatabaseSize()
-			if err != nil {
-				fmt.Println("Failed to get database size. Reason: ", err)
-				continue
-			}
-			dataInserted := curSize - initialSize
-			progress := float64(dataInserted) * 100 / float64(desiredAmount)
-			if curSize > previousSize {
-				fmt.Printf("Progress: %.2f%% Data Inserted: %s Current %q Size: %s\n", progress, formatSize(dataInserted), opt.dbName, formatSize(curSize))
-				previousSize = curSize
-			}
-			if progress >= 100 {
-				fmt.Println("Successfully inserted sample data......")
-				return
+	return func(p generator.Progress) {
+		if p.Done {
+			if opt.duration > 0 && progress.elapsed() >= opt.duration {
+				out.info("Duration %s has elapsed......", opt.duration.String())
 			}
+			out.phase("stopping", "Stopping data insertion...")
+			return
 		}
-	}
-}
-
-func (opt *GeneratorOptions) getDatabaseSize() (int, error) {
-	// make sure the table statistics has been updated
-	// refs:
-	// - https://dba.stackexchange.com/questions/236863/wrong-innodb-table-status-size-rows-after-updating-from-mysql-5-7-to-8
-	// - https://dev.mysql.com/doc/refman/8.0/en/check-table.html
-	// - https://dev.mysql.com/doc/refman/8.0/en/analyze-table.html
-	tables := make([]string, 0)
-	for i := 0; i < opt.tableNumber; i++ {
-		tables = append(tables, fmt.Sprintf("table%d", i))
-	}
-	_, err := db.Query(fmt.Sprintf("CHECK TABLE %s;", strings.Join(tables, ",")))
-	if err != nil {
-		return 0, err
-	}
-	_, err = db.Query(fmt.Sprintf("ANALYZE TABLE %s;", strings.Join(tables, ",")))
-	if err != nil {
-		return 0, err
-	}
-
-	statement := fmt.Sprintf("SELECT table_schema, round(SUM(data_length + index_length)) FROM information_schema.TABLES WHERE table_schema = %q;", opt.dbName)
-	rows, err := db.Query(statement)
-	if err != nil {
-		fmt.Println("failed to execute query. Reason: ", err)
-		return 0, err
-	}
-	defer rows.Close()
-
-	var dbname, size sql.RawBytes
-	for rows.Next() {
-		rows.Scan(&dbname, &size)
-		if size != nil {
-			sizeInByte, err := strconv.Atoi(string(size))
-			if err != nil {
-				fmt.Println("Failed parsing size. reason: ", err)
-				return 0, err
-			}
-			return sizeInByte, nil
+		// the generator measures the size of size bounded runs only
+		curSize, err := int(p.DatabaseSize), p.SizeErr
+		if desiredAmount == 0 {
+			curSize, err = opt.databaseSize(sink)
 		}
-	}
-	return 0, nil
-}
-
-func (opt *GeneratorOptions) getClient(database string) (*sql.DB, error) {
-	param := "tls=false"
-	if opt.requireTLS {
-		certPool := x509.NewCertPool()
-		certPool.AppendCertsFromPEM([]byte(opt.caCert))
-
-		cert, err := tls.LoadX509KeyPair(clientCerts, clientKey)
 		if err != nil {
-			log.Fatal(err)
+			out.error(err, "Failed to get database size")
+			return
 		}
-		// requireTLS custom setup
-		err = mysql.RegisterTLSConfig("custom", &tls.Config{
-			RootCAs: certPool,
-			Certificates: []tls.Certificate{cert},
-		})
-		if err != nil {
-			return nil, err
+		metrics.setDatabaseSize(curSize)
+		dataInserted := curSize - initialSize
+		var percent float64
+		if desiredAmount > 0 {
+			percent = float64(dataInserted) * 100 / float64(desiredAmount)
+		} else {
+			percent = float64(progress.elapsed()) * 100 / float64(opt.duration)
+		}
+		if curSize > previousSize {
+			out.progress(percent, dataInserted, int(atomic.LoadInt64(&metrics.rowsInserted)), curSize, opt.dbName)
+			previousSize = curSize
+		}
+		if desiredAmount > 0 && percent >= 100 {
+			out.info("Successfully inserted sample data......")
 		}
-		param = fmt.Sprintf("tls=%s", "custom")
 	}
+}
 
-	dns := fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?%v", opt.user, opt.password, opt.host, opt.port, database, param)
-	db, err := sql.Open("mysql", dns)
-	if err != nil {
-		return nil, err
+// databaseSize returns the size of the database as measured by the sink
+func (opt *GeneratorOptions) databaseSize(sink generator.Measurer) (int, error) {
+	tables := make([]string, opt.tableNumber)
+	for i := range tables {
+		tables[i] = generator.TableName(i)
 	}
-	return db, nil
+	size, err := sink.Size(context.Background(), opt.dbName, tables)
+	return int(size), err
 }
 
-func (opt *GeneratorOptions) storeCerts() error {
-	// store ca-cert, client-cert and client-key file to '/mysql/certs/' directory
-	if opt.caCert != "" {
-		if err := ioutil.WriteFile(ca, []byte(opt.caCert), os.ModePerm); err != nil {
-			return err
-		}
-	}
-	if opt.clientCert != "" {
-		if err := ioutil.WriteFile(clientCerts, []byte(opt.clientCert), os.ModePerm); err != nil {
-			return err
-		}
+// getClient returns a client for the database. The configure functions can adjust
+// the driver configuration of the client.
+func (opt *GeneratorOptions) getClient(database string, configure ...func(cfg *mysql.Config)) (*sql.DB, error) {
+	cfg, err := opt.mysqlConfig(database)
+	if err != nil {
+		return nil, err
 	}
-	if opt.clientKey != "" {
-		if err := ioutil.WriteFile(clientKey, []byte(opt.clientKey), os.ModePerm); err != nil {
-			return err
-		}
+	for _, fn := range configure {
+		fn(cfg)
 	}
-	return nil
+	return openDB(cfg)
+}
+
+// openDB opens a client with the driver configuration. The tests replace it to
+// run against an in-process stand-in of the server.
+var openDB = func(cfg *mysql.Config) (*sql.DB, error) {
+	return sql.Open("mysql", cfg.FormatDSN())
 }
 
 func formatSize(size int) string {
@@ -429,35 +727,7 @@ func formatSize(size int) string {
 		return fmt.Sprintf("%.3f GB", float64(size)/OneGB)
 	}
 }
-func generateName() string {
-	return fmt.Sprintf("%s %s", strings.Title(adjectives[rand.Int()%totalAdjectives]), strings.Title(nouns[rand.Int()%totalNouns]))
-}
-
 func (opt *GeneratorOptions) parseSize() (int, error) {
-	var amount float64
-	var unit string
-	_, err := fmt.Sscanf(opt.size, "%f%s", &amount, &unit)
-	if err != nil {
-		return 0, err
-	}
-
-	switch unit {
-	case "KB", "K":
-		return int(amount * 1024), nil
-	case "MB", "Mi":
-		return int(amount * 1024 * 1024), nil
-	case "GB", "Gi":
-		return int(amount * 1024 * 1024 * 1024), nil
-	default:
-		return 0, fmt.Errorf("expected data unit to one of (KB,K, MB,Mi, GB,Gi). Found: %s", unit)
-	}
+	size, err := generator.ParseSize(opt.size)
+	return int(size), err
 }
-
-var (
-	adjectives      = []string{"affable", "affectionate", "agreeable", "ambitious", "amiable", "amicable", "amusing", "brave", "bright", "broad-minded", "calm", "careful", "charming", "communicative", "compassionate", "conscientious", "considerate", "convivial", "courageous", "courteous", "creative", "decisive", "determined", "diligent", "diplomatic", "discreet", "dynamic", "easygoing", "emotional", "energetic", "enthusiastic", "exuberant", "fair-minded", "faithful", "fearless", "forceful", "frank", "friendly", "funny", "generous", "gentle", "good", "gregarious", "hard-working", "helpful", "honest", "humorous", "imaginative", "impartial", "independent", "intellectual", "intelligent", "intuitive", "inventive", "kind", "loving", "loyal", "modest", "neat", "nice", "optimistic", "passionate", "patient", "persistent", "pioneering", "philosophical", "placid", "plucky", "polite", "powerful", "practical", "pro-active", "quick-witted", "quiet", "rational", "reliable", "reserved", "resourceful", "romantic", "self-confident", "self-disciplined", "sensible", "sensitive", "shy", "sincere", "sociable", "straightforward", "sympathetic", "thoughtful", "tidy", "tough", "unassuming", "understanding", "versatile", "warmhearted", "willing", "witty"}
-	totalAdjectives = 97
-
-	nouns      = []string{"John", "William", "James", "Charles", "George", "Frank", "Joseph", "Thomas", "Henry", "Robert", "Edward", "Harry", "Walter", "Arthur", "Fred", "Albert", "Samuel", "David", "Louis", "Joe", "Charlie", "Clarence", "Richard", "Andrew", "Daniel", "Ernest", "Will", "Jesse", "Oscar", "Lewis", "Peter", "Benjamin", "Frederick", "Willie", "Alfred", "Sam", "Roy", "Herbert", "Jacob", "Tom", "Elmer", "Carl", "Lee", "Howard", "Martin", "Michael", "Bert", "Herman", "Jim", "Francis", "Harvey", "Earl", "Eugene", "Ralph", "Ed", "Claude", "Edwin", "Ben", "Charley", "Paul", "Edgar", "Isaac", "Otto", "Luther", "Lawrence", "Ira", "Patrick", "Guy", "Oliver", "Theodore", "Hugh", "Clyde", "Alexander", "August", "Floyd", "Homer", "Jack", "Leonard", "Horace", "Marion", "Philip", "Allen", "Archie", "Stephen", "Chester", "Willis", "Raymond", "Rufus", "Warren", "Jessie", "Milton", "Alex", "Leo", "Julius", "Ray", "Sidney", "Bernard", "Dan", "Jerry", "Calvin", "Perry", "Dave", "Anthony", "Eddie", "Amos", "Dennis", "Clifford", "Leroy", "Wesley", "Alonzo", "Garfield", "Franklin", "Emil", "Leon", "Nathan", "Harold", "Matthew", "Levi", "Moses", "Everett", "Lester", "Winfield", "Adam", "Lloyd", "Mack", "Fredrick", "Jay", "Jess", "Melvin", "Noah", "Aaron", "Alvin", "Norman", "Gilbert", "Elijah", "Victor", "Gus", "Nelson", "Jasper", "Silas", "Christopher", "Jake", "Mike", "Percy", "Adolph", "Maurice", "Cornelius", "Felix", "Reuben", "Wallace", "Claud", "Roscoe", "Sylvester", "Earnest", "Hiram", "Otis", "Simon", "Willard", "Irvin", "Mark", "Jose", "Wilbur", "Abraham", "Virgil", "Clinton", "Elbert", "Leslie", "Marshall", "Owen", "Wiley", "Anton", "Morris", "Manuel", "Phillip", "Augustus", "Emmett", "Eli", "Nicholas", "Wilson", "Alva", "Harley", "Newton", "Timothy", "Marvin", "Ross", "Curtis", "Edmund", "Jeff", "Elias", "Harrison", "Stanley", "Columbus", "Lon", "Ora", "Ollie", "Russell", "Pearl", "Solomon", "Arch", "Asa", "Clayton", "Enoch", "Irving", "Mathew", "Nathaniel", "Scott", "Hubert", "Lemuel", "Andy", "Ellis", "Emanuel", "Joshua", "Millard", "Vernon", "Wade", "Cyrus", "Miles", "Rudolph", "Sherman", "Austin", "Bill", "Chas", "Lonnie", "Monroe", "Byron", "Edd", "Emery", "Grant", "Jerome", "Max", "Mose", "Steve", "Gordon", "Abe", "Pete", "Chris", "Clark", "Gustave", "Orville", "Lorenzo", "Bruce", "Marcus", "Preston", "Bob", "Dock", "Donald", "Jackson", "Cecil", "Barney", "Delbert", "Edmond", "Anderson", "Christian", "Glenn", "Jefferson", "Luke", "Neal", "Burt", "Ike", "Myron", "Tony", "Conrad", "Joel", "Matt", "Riley", "Vincent", "Emory", "Isaiah", "Nick", "Ezra", "Green", "Juan", "Clifton", "Lucius", "Porter", "Arnold", "Bud", "Jeremiah", "Taylor", "Forrest", "Roland", "Spencer", "Burton", "Don", "Emmet", "Gustav", "Louie", "Morgan", "Ned", "Van", "Ambrose", "Chauncey", "Elisha", "Ferdinand", "General", "Julian", "Kenneth", "Mitchell", "Allie", "Josh", "Judson", "Lyman", "Napoleon", "Pedro", "Berry", "Dewitt", "Ervin", "Forest", "Lynn", "Pink", "Ruben", "Sanford", "Ward", "Douglas", "Ole", "Omer", "Ulysses", "Walker", "Wilbert", "Adelbert", "Benjiman", "Ivan", "Jonas", "Major", "Abner", "Archibald", "Caleb", "Clint", "Dudley", "Granville", "King", "Mary", "Merton", "Antonio", "Bennie", "Carroll", "Freeman", "Josiah", "Milo", "Royal", "Dick", "Earle", "Elza", "Emerson", "Fletcher", "Judge", "Laurence", "Neil", "Roger", "Seth", "Glen", "Hugo", "Jimmie", "Johnnie", "Washington", "Elwood", "Gust", "Harmon", "Jordan", "Simeon", "Wayne", "Wilber", "Clem", "Evan", "Frederic", "Irwin", "Junius", "Lafayette", "Loren", "Madison", "Mason", "Orval", "Abram", "Aubrey", "Elliott", "Hans", "Karl", "Minor", "Wash", "Wilfred", "Allan", "Alphonse", "Dallas", "Dee", "Isiah", "Jason", "Johnny", "Lawson", "Lew", "Micheal", "Orin", "Addison", "Cal", "Erastus", "Francisco", "Hardy", "Lucien", "Randolph", "Stewart", "Vern", "Wilmer", "Zack", "Adrian", "Alvah", "Bertram", "Clay", "Ephraim", "Fritz", "Giles", "Grover", "Harris", "Isom", "Jesus", "Johnie", "Jonathan", "Lucian", "Malcolm", "Merritt", "Otho", "Perley", "Rolla", "Sandy", "Tomas", "Wilford", "Adolphus", "Angus", "Arther", "Carlos", "Cary", "Cassius", "Davis", "Hamilton", "Harve", "Israel", "Leander", "Melville", "Merle", "Murray", "Pleasant", "Sterling", "Steven", "Axel", "Boyd", "Bryant", "Clement", "Erwin", "Ezekiel", "Foster", "Frances", "Geo", "Houston", "Issac", "Jules", "Larkin", "Mat", "Morton", "Orlando", "Pierce", "Prince", "Rollie", "Rollin", "Sim", "Stuart", "Wilburn", "Bennett", "Casper", "Christ", "Dell", "Egbert", "Elmo", "Fay", "Gabriel", "Hector", "Horatio", "Lige", "Saul", "Smith", "Squire", "Tobe", "Tommie", "Wyatt", "Alford", "Alma", "Alton", "Andres", "Burl", "Cicero", "Dean", "Dorsey", "Enos", "Howell", "Lou", "Loyd", "Mahlon", "Nat", "Omar", "Oran", "Parker", "Raleigh", "Reginald", "Rubin", "Seymour", "Wm", "Young", "Benjamine", "Carey", "Carlton", "Eldridge", "Elzie", "Garrett", "Isham", "Johnson", "Larry", "Logan", "Merrill", "Mont", "Oren", "Pierre", "Rex", "Rodney", "Ted", "Webster", "West", "Wheeler", "Willam", "Al", "Aloysius", "Alvie", "Anna", "Art", "Augustine", "Bailey", "Benjaman", "Beverly", "Bishop", "Clair", "Cloyd", "Coleman", "Dana", "Duncan", "Dwight", "Emile", "Evert", "Henderson", "Hunter", "Jean", "Lem", "Luis", "Mathias", "Maynard", "Miguel", "Mortimer", "Nels", "Norris", "Pat", "Phil", "Rush", "Santiago", "Sol", "Sydney", "Thaddeus", "Thornton", "Tim", "Travis", "Truman", "Watson", "Webb", "Wellington", "Winfred", "Wylie", "Alec", "Basil", "Baxter", "Bertrand", "Buford", "Burr", "Cleveland", "Colonel", "Dempsey", "Early", "Ellsworth", "Fate", "Finley", "Gabe", "Garland", "Gerald", "Herschel", "Hezekiah", "Justus", "Lindsey", "Marcellus", "Olaf", "Olin", "Pablo", "Rolland", "Turner", "Verne", "Volney", "Williams", "Almon", "Alois", "Alonza", "Anson", "Authur", "Benton", "Billie", "Cornelious", "Darius", "Denis", "Dillard", "Doctor", "Elvin", "Emma", "Eric", "Evans", "Gideon", "Haywood", "Hilliard", "Hosea", "Lincoln", "Lonzo", "Lucious", "Lum", "Malachi", "Newt", "Noel", "Orie", "Palmer", "Pinkney", "Shirley", "Sumner", "Terry", "Urban", "Uriah", "Valentine", "Waldo", "Warner", "Wong", "Zeb", "Abel", "Alden", "Archer", "Avery", "Carson", "Cullen", "Doc", "Eben", "Elige", "Elizabeth", "Elmore", "Ernst", "Finis", "Freddie", "Godfrey", "Guss", "Hamp", "Hermann", "Isadore", "Isreal", "Jones", "June", "Lacy", "Lafe", "Leland", "Llewellyn", "Ludwig", "Manford", "Maxwell", "Minnie", "Obie", "Octave", "Orrin", "Ossie", "Oswald", "Park", "Parley", "Ramon", "Rice", "Stonewall", "Theo", "Tillman", "Addie", "Aron", "Ashley", "Bernhard", "Bertie", "Berton", "Buster", "Butler", "Carleton", "Carrie", "Clara", "Clarance", "Clare", "Crawford", "Danial", "Dayton", "Dolphus", "Elder", "Ephriam", "Fayette", "Felipe", "Fernando", "Flem", "Florence", "Ford", "Harlan", "Hayes", "Henery", "Hoy", "Huston", "Ida", "Ivory", "Jonah", "Justin", "Lenard", "Leopold", "Lionel", "Manley", "Marquis", "Marshal", "Mart", "Odie", "Olen", "Oral", "Orley", "Otha", "Press", "Price", "Quincy", "Randall", "Rich", "Richmond", "Romeo", "Russel", "Rutherford", "Shade", "Shelby", "Solon", "Thurman", "Tilden", "Troy", "Woodson", "Worth", "Aden", "Alcide", "Alf", "Algie", "Arlie", "Bart", "Bedford", "Benito", "Billy", "Bird", "Birt", "Bruno", "Burley", "Chancy", "Claus", "Cliff", "Clovis", "Connie", "Creed", "Delos", "Duke", "Eber", "Eligah", "Elliot", "Elton", "Emmitt", "Gene", "Golden", "Hal", "Hardin", "Harman", "Hervey", "Hollis", "Ivey", "Jennie", "Len", "Lindsay", "Lonie", "Lyle", "Mac", "Mal", "Math", "Miller", "Orson", "Osborne", "Percival", "Pleas", "Ples", "Rafael", "Raoul", "Roderick", "Rose", "Shelton", "Sid", "Theron", "Tobias", "Toney", "Tyler", "Vance", "Vivian", "Walton", "Watt", "Weaver", "Wilton", "Adolf", "Albin", "Albion", "Allison", "Alpha", "Alpheus", "Anastacio", "Andre", "Annie", "Arlington", "Armand", "Asberry", "Asbury", "Asher", "Augustin", "Auther", "Author", "Ballard", "Blas", "Caesar", "Candido", "Cato", "Clarke", "Clemente", "Colin", "Commodore", "Cora", "Coy", "Cruz", "Curt", "Damon", "Davie", "Delmar", "Dexter", "Dora", "Doss", "Drew", "Edson", "Elam", "Elihu", "Eliza", "Elsie", "Erie", "Ernie", "Ethel", "Ferd", "Friend", "Garry", "Gary", "Grace", "Gustaf", "Hallie", "Hampton", "Harrie", "Hattie", "Hence", "Hillard", "Hollie", "Holmes", "Hope", "Hyman", "Ishmael", "Jarrett", "Jessee", "Joeseph", "Junious", "Kirk", "Levy", "Mervin", "Michel", "Milford", "Mitchel", "Nellie", "Noble", "Obed", "Oda", "Orren", "Ottis", "Rafe", "Redden", "Reese", "Rube", "Ruby", "Rupert", "Salomon", "Sammie", "Sanders", "Soloman", "Stacy", "Stanford", "Stanton", "Thad", "Titus", "Tracy", "Vernie", "Wendell", "Wilhelm", "Willian", "Yee", "Zeke", "Ab", "Abbott", "Agustus", "Albertus", "Almer", "Alphonso", "Alvia", "Artie", "Arvid", "Ashby", "Augusta", "Aurthur", "Babe", "Baldwin", "Barnett", "Bartholomew", "Barton", "Bernie", "Blaine", "Boston", "Brad", "Bradford", "Bradley", "Brooks", "Buck", "Budd", "Ceylon", "Chalmers", "Chesley", "Chin", "Cleo", "Crockett", "Cyril", "Daisy", "Denver", "Dow", "Duff", "Edie", "Edith", "Elick", "Elie", "Eliga", "Eliseo", "Elroy", "Ely", "Ennis", "Enrique", "Erasmus", "Esau", "Everette", "Firman", "Fleming", "Flora", "Gardner", "Gee", "Gorge", "Gottlieb", "Gregorio", "Gregory", "Gustavus", "Halsey", "Handy", "Hardie", "Harl", "Hayden", "Hays", "Hermon", "Hershel", "Holly", "Hosteen", "Hoyt", "Hudson", "Huey", "Humphrey", "Hunt", "Hyrum", "Irven", "Isam", "Ivy", "Jabez", "Jewel", "Jodie", "Judd", "Julious", "Justice", "Katherine", "Kelly", "Kit", "Knute", "Lavern", "Lawyer", "Layton"}
-	totalNouns = 1000
-	loremIpsum = "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Sed quam felis, interdum in porttitor lacinia, ornare id neque. Ut facilisis rutrum dui, in consectetur nisl. Nulla in augue ut velit bibendum tempor nec sed odio. Phasellus quam mi, rhoncus ut vehicula a, sollicitudin imperdiet massa. Mauris eget lacus in tellus semper suscipit nec eget sem.Lorem ipsum dolor sit amet, consectetur adipiscing elit. Ut imperdiet augue augue, quis tincidunt massa ullamcorper sed. Integer sit amet dapibus quam, ut laoreet ipsum. Pellentesque id bibendum ipsum. Maecenas egestas, purus nec dignissim euismod, neque ipsum dapibus purus, eu maximus elit purus quis mauris. Orci varius natoque penatibus et magnis dis parturient montes, nascetur ridiculus mus. Morbi vel tellus iaculis, sodales lectus id, pulvinar nunc.\n\nCras in euismod orci. Vestibulum a ex tincidunt, tincidunt nisi a, pretium eros. Maecenas efficitur porta justo sed gravida. Aliquam mi mi, vehicula quis orci ac, efficitur blandit urna. Class aptent taciti sociosqu ad litora torquent per conubia nostra, per inceptos himenaeos. Phasellus arcu eros, dignissim at elit eget, commodo suscipit quam. Aliquam dictum ipsum in nibh mollis, sit amet semper nibh imperdiet. Mauris hendrerit, lacus id tristique aliquam, ex ipsum consectetur est, ut placerat arcu sapien eget nulla. Maecenas dictum magna quis dapibus rhoncus. Quisque convallis arcu mi, non commodo nulla scelerisque a. Nunc ut felis erat. Morbi vel ante consequat, tincidunt erat in, condimentum orci.\n\nPhasellus porttitor, nunc quis pretium scelerisque, lacus tellus finibus orci, tempor laoreet justo mauris id purus. Donec ex ante, feugiat a dui aliquam, pharetra malesuada lectus. Nullam augue risus, porttitor sit amet volutpat ullamcorper, ornare sed elit. Morbi diam sem, dapibus id ullamcorper scelerisque, porta ut urna. Aenean et mi consectetur, tempor lorem a, vestibulum massa. Pellentesque eu est commodo, sodales erat sed, sagittis leo. Ut nec viverra diam. Nullam urna sem, tincidunt in blandit sit amet, efficitur id erat. Etiam sollicitudin accumsan ante, ac dictum lacus consequat a. Phasellus molestie nunc enim, at pharetra quam efficitur vitae.\n\nDonec ullamcorper, mauris placerat iaculis ullamcorper, libero sapien tincidunt leo, venenatis dignissim neque sapien a turpis. Donec sodales tincidunt turpis a faucibus. Quisque id mi a metus ultricies consectetur. Sed tempus et enim et dapibus. Cras ac pulvinar leo. Nam at tincidunt nulla, eu ornare diam. Proin id viverra augue. Aliquam eget mattis ante, sit amet ornare urna. Praesent iaculis laoreet augue quis pharetra. Duis venenatis elementum neque et suscipit. Etiam commodo tellus eu gravida commodo. Nunc in mattis ligula. Sed eleifend, leo at porta vehicula, ipsum felis sollicitudin magna, non eleifend dui nisl et turpis. Proin ut tortor eu leo interdum laoreet.Lorem ipsum dolor sit amet, consectetur adipiscing elit. In mi nisi, scelerisque semper ligula sit amet, vulputate pulvinar ex. Ut sed nisi blandit velit pretium dapibus a in enim. Cras congue sagittis massa, ac semper tellus imperdiet nec. Donec eu viverra diam, sed faucibus neque. In tincidunt enim sem, et consequat massa laoreet non. Nunc fringilla dolor vel dui mollis scelerisque. Suspendisse fermentum mauris quis ex ultricies, rhoncus scelerisque erat maximus. Phasellus venenatis at dolor quis consectetur. Maecenas facilisis bibendum pellentesque. Nulla tincidunt tincidunt tellus a mollis. Donec bibendum purus sed ipsum pulvinar, et viverra enim fringilla. Aliquam sed laoreet metus, non placerat enim. Nullam eget condimentum metus, id convallis metus.\n\nVestibulum molestie posuere molestie. Aliquam accumsan euismod nulla. Fusce a volutpat urna. Proin efficitur orci at dui aliquet, a ornare justo pulvinar. Morbi nisi nisi, molestie lacinia nisi at, dapibus faucibus nisl. Vestibulum vel scelerisque lorem. Donec viverra orci in auctor fermentum. Phasellus urna libero, suscipit sed rutrum in, vestibulum id elit. Cras auctor auctor magna non mattis. Etiam vel venenatis erat. Quisque nec nisi eu ante porttitor scelerisque quis eget velit. Cras molestie ligula in nulla feugiat, sit amet egestas sapien vulputate. Praesent faucibus auctor congue.\n\nVivamus accumsan hendrerit consequat. Nam auctor turpis arcu, vel maximus justo ultricies ac. Morbi eget finibus dui. Vivamus feugiat fringilla nisl semper rhoncus. Sed ac condimentum risus. Donec et fringilla orci. Vestibulum ante ipsum primis in faucibus orci luctus et ultrices posuere cubilia curae; Donec at mattis sem.\n\nCras placerat orci ut aliquet maximus. Curabitur non dapibus mauris. Ut hendrerit, arcu non laoreet eleifend, mi nunc consequat velit, eu egestas ex metus eget nisi. Maecenas id sodales mauris. Pellentesque vitae sem magna. Maecenas accumsan malesuada nunc, sit amet mattis massa semper sit amet. Vivamus malesuada dapibus quam, ac malesuada justo. Maecenas tristique urna sit amet ante iaculis, ac placerat ipsum blandit.\n\nCras odio odio, molestie sed consequat et, molestie sed elit. Fusce at fringilla libero. Class aptent taciti sociosqu ad litora torquent per conubia nostra, per inceptos himenaeos. Phasellus id pretium libero, interdum auctor sem. Curabitur eleifend varius pellentesque. Phasellus porta tincidunt porta. Nulla facilisis nec velit vel malesuada. Cras blandit neque sed laoreet gravida.\n\nDonec eget diam suscipit, tempus elit et, vehicula sem. Donec lacinia risus eget ligula tincidunt fringilla. Proin ornare convallis tempor. Nullam eget ipsum lacus. Etiam lacinia, leo vitae feugiat euismod, lorem erat accumsan justo, vel porttitor nunc dolor non est. Nunc sagittis auctor lectus, nec volutpat eros varius non. Vivamus eget ultrices mauris. Vestibulum porta nibh in egestas ornare. Sed eu enim congue, dapibus nulla non, rhoncus nisl. Nullam vitae nibh id justo lacinia iaculis. Pellentesque purus justo, gravida sed tellus quis, vehicula porttitor augue. In hac habitasse platea dictumst. Aliquam blandit augue ac posuere commodo. Nullam sollicitudin nisl nec metus egestas tincidunt.\n\nDonec cursus, arcu ut ultricies facilisis, nulla augue semper nulla, sit amet aliquet diam enim ac odio. Mauris lobortis porta nulla. Aliquam enim mauris, blandit in hendrerit id, lacinia eu erat. Aliquam sodales porta mollis. Duis feugiat sodales egestas. Nunc mi elit, varius sed velit at, tempus varius ligula. Morbi augue urna, rhoncus eget tempus eu, lacinia sit amet odio. Aenean posuere leo velit, vestibulum scelerisque erat ullamcorper id. Etiam vestibulum molestie est. Orci varius natoque penatibus et magnis dis parturient montes, nascetur ridiculus mus. Proin at massa faucibus, consequat mauris ut, interdum turpis. Nunc laoreet nibh vel nulla euismod egestas. Integer magna nisi, dignissim non nisl eget, cursus tincidunt ligula. Sed in pretium tortor, sed imperdiet quam. Vestibulum aliquam arcu dui, quis varius erat vehicula in. Donec rhoncus sit amet ipsum nec pharetra.\n\nPhasellus vulputate faucibus laoreet. Vestibulum imperdiet risus eu est facilisis, sed facilisis velit dapibus. Aenean maximus tristique tortor, non consequat risus rutrum in. Praesent sollicitudin, nunc vitae euismod ullamcorper, augue libero commodo nisi, sed imperdiet arcu tortor non ligula. Aliquam vitae viverra est. Aenean egestas, nibh vel posuere sagittis, elit est bibendum tortor, a ornare nisi est vitae eros. Phasellus sagittis pulvinar sapien, vitae faucibus mi. Aenean fringilla ipsum nunc. Pellentesque condimentum, est non dignissim convallis, ipsum orci vulputate nunc, vitae semper tellus dui quis nibh. Quisque rhoncus magna volutpat vestibulum luctus.\n\nNam sed augue augue. Praesent hendrerit mauris non libero posuere, vitae efficitur purus varius. Etiam quis varius metus. Nam pellentesque sapien id elit suscipit iaculis. Nulla interdum elit gravida, lobortis ligula et, maximus orci. Nullam non turpis sed libero tempus laoreet. Vestibulum odio augue, tincidunt eget nulla vel, scelerisque malesuada metus. Aenean id nulla sed nunc bibendum molestie. Aliquam eget dolor tempor neque pharetra elementum. Etiam fermentum mattis augue non imperdiet. Nunc malesuada ante metus, ac pulvinar odio vehicula nec. Nunc placerat mi non nisi pharetra eleifend. Mauris maximus urna quis ante varius, ut ultrices massa aliquet.\n\nNullam turpis nisi, eleifend vehicula enim vel, tempus sodales neque. Duis non fringilla arcu, id ultrices nunc. Morbi porta odio et urna lacinia tincidunt. Nullam pellentesque lorem vitae purus laoreet consectetur. Maecenas eget sapien finibus, condimentum erat at, pulvinar mi. Mauris mattis ex tincidunt sapien semper, et iaculis libero rhoncus. Vivamus egestas, nulla et pharetra suscipit, mi purus vehicula nulla, nec pretium mauris purus molestie magna. Aliquam erat volutpat. Nulla et libero in libero porttitor convallis. In congue hendrerit arcu, sed blandit lacus pretium eleifend."
-)
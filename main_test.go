@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int
+		wantErr bool
+	}{
+		{size: "128MB", want: 128 * OneMB},
+		{size: "512KB", want: 512 * OneKB},
+		{size: "1Gi", want: OneGB},
+		{size: "1.5GB", want: 3 * OneGB / 2},
+		{size: "100", wantErr: true},
+		{size: "1TB", wantErr: true},
+		{size: "", wantErr: true},
+	}
+	for _, test := range tests {
+		opt := GeneratorOptions{size: test.size}
+		got, err := opt.parseSize()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseSize(%q) = %d, want an error", test.size, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", test.size, got, err, test.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int
+		want string
+	}{
+		{size: 0, want: "0.000 B"},
+		{size: OneKB, want: "1024.000 B"},
+		{size: OneKB + 512, want: "1.500 KB"},
+		{size: OneMB, want: "1024.000 KB"},
+		{size: 3 * OneMB / 2, want: "1.500 MB"},
+		{size: 5 * OneGB, want: "5.000 GB"},
+	}
+	for _, test := range tests {
+		if got := formatSize(test.size); got != test.want {
+			t.Errorf("formatSize(%d) = %q, want %q", test.size, got, test.want)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want errorClass
+	}{
+		{err: &mysql.MySQLError{Number: ErrLockDeadlock}, want: classDeadlock},
+		{err: &mysql.MySQLError{Number: ErrLockWaitTimeout}, want: classLockWaitTimeout},
+		{err: &mysql.MySQLError{Number: ErrServerGone}, want: classConnectionLost},
+		{err: &mysql.MySQLError{Number: ErrReadOnlyMode}, want: classReadOnly},
+		{err: &mysql.MySQLError{Number: ErrOptionPreventsRun}, want: classReadOnly},
+		{err: &mysql.MySQLError{Number: ErrDiskFull}, want: classDiskFull},
+		{err: &mysql.MySQLError{Number: ErrRecordFileFull}, want: classDiskFull},
+		{err: &mysql.MySQLError{Number: ErrTableAccessDenied}, want: classAccessDenied},
+		{err: &mysql.MySQLError{Number: ErrAccessDenied}, want: classAccessDenied},
+		{err: &mysql.MySQLError{Number: ErrNoSuchTable}, want: classMissingSchema},
+		{err: &mysql.MySQLError{Number: ErrBadDB}, want: classMissingSchema},
+		{err: &mysql.MySQLError{Number: ErrDuplicateKey}, want: classDuplicateKey},
+		{err: &mysql.MySQLError{Number: 1064}, want: classOther},
+		{err: fmt.Errorf("failed to insert. Reason: %w", &mysql.MySQLError{Number: ErrLockDeadlock}), want: classDeadlock},
+		{err: driver.ErrBadConn, want: classConnectionLost},
+		{err: mysql.ErrInvalidConn, want: classConnectionLost},
+		{err: errors.New("unknown"), want: classOther},
+	}
+	for _, test := range tests {
+		if got := classifyError(test.err); got != test.want {
+			t.Errorf("classifyError(%v) = %s, want %s", test.err, got.name, test.want.name)
+		}
+	}
+}
+
+func TestErrorBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	budget := newErrorBudget(2, cancel)
+	other := &mysql.MySQLError{Number: 1064, Message: "syntax error"}
+	for i := 0; i < 2; i++ {
+		if err := budget.add(other); err != nil {
+			t.Fatalf("failure %d aborted the run within the budget: %v", i+1, err)
+		}
+	}
+	if err := budget.add(other); !errors.Is(err, other) {
+		t.Errorf("failure beyond the budget = %v, want an error wrapping %v", err, other)
+	}
+	if budget.failed() != 3 {
+		t.Errorf("failed() = %d, want 3", budget.failed())
+	}
+
+	diskFull := &mysql.MySQLError{Number: ErrDiskFull, Message: "disk full"}
+	if err := newErrorBudget(0, cancel).add(diskFull); !errors.Is(err, diskFull) {
+		t.Errorf("fatal failure = %v, want an error wrapping %v", err, diskFull)
+	}
+
+	budget.abort(other)
+	budget.abort(diskFull)
+	if err := budget.failure(); err != other {
+		t.Errorf("failure() = %v, want the first error %v", err, other)
+	}
+	if ctx.Err() == nil {
+		t.Error("abort() did not cancel the run")
+	}
+}
+
+func TestMysqlConfigDSN(t *testing.T) {
+	commandLine := flag.CommandLine
+	defer func() {
+		flag.CommandLine = commandLine
+	}()
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	flag.CommandLine.String("user", "", "")
+	flag.CommandLine.String("password", "", "")
+	flag.CommandLine.Int("port", 0, "")
+
+	opt := GeneratorOptions{dsn: "app:secret@tcp(db:3306)/ignored", host: "localhost", port: 3306, user: "root", password: "env"}
+	cfg, err := opt.mysqlConfig("sampleData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.User != "app" || cfg.Passwd != "secret" || cfg.Addr != "db:3306" || cfg.DBName != "sampleData" {
+		t.Errorf("config = %s:%s@%s/%s, want the DSN parameters", cfg.User, cfg.Passwd, cfg.Addr, cfg.DBName)
+	}
+
+	if err := flag.CommandLine.Parse([]string{"--user=admin", "--password=flag", "--port=3307"}); err != nil {
+		t.Fatal(err)
+	}
+	opt.user, opt.password, opt.port = "admin", "flag", 3307
+	cfg, err = opt.mysqlConfig("sampleData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.User != "admin" || cfg.Passwd != "flag" || cfg.Addr != "localhost:3307" {
+		t.Errorf("config = %s:%s@%s, want the explicit flags to override the DSN", cfg.User, cfg.Passwd, cfg.Addr)
+	}
+
+	opt.dsn = "app:secret@tcp(db:3306)/?connectionAttributes=program_name:e2e"
+	opt.connAttributes = defaultConnAttributes
+	if cfg, err = opt.mysqlConfig("sampleData"); err != nil || cfg.ConnectionAttributes != "program_name:e2e" {
+		t.Errorf("mysqlConfig() with connection attributes = %v, %v, want the attributes of the DSN", cfg, err)
+	}
+	opt.dsn = ""
+	if cfg, err = opt.mysqlConfig("sampleData"); err != nil || cfg.ConnectionAttributes != defaultConnAttributes {
+		t.Errorf("mysqlConfig() without connection attributes = %v, %v, want the default attributes", cfg, err)
+	}
+}
+
+func TestDelimitedFormat(t *testing.T) {
+	commandLine := flag.CommandLine
+	defer func() {
+		flag.CommandLine = commandLine
+	}()
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	opt := GeneratorOptions{exportFormat: ExportCSV}
+	flag.CommandLine.StringVar(&opt.csvDelimiter, "csv-delimiter", "", "")
+	flag.CommandLine.StringVar(&opt.csvQuote, "csv-quote", "", "")
+
+	for _, args := range [][]string{{`--csv-delimiter=\t`}, {"--csv-quote="}} {
+		if err := flag.CommandLine.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := opt.delimitedFormat(); err != nil {
+			t.Errorf("delimitedFormat() with %v failed: %v", args, err)
+		}
+	}
+	for _, args := range [][]string{{"--csv-delimiter=||"}, {"--csv-delimiter=,", `--csv-quote=""`}} {
+		if err := flag.CommandLine.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := opt.delimitedFormat(); err == nil {
+			t.Errorf("delimitedFormat() with %v succeeded, want an error", args)
+		}
+	}
+}
+
+func TestRunStressColumns(t *testing.T) {
+	opt := GeneratorOptions{stressColumns: 0, stressTxnSizes: "1MB"}
+	if err := opt.runStress(); err == nil || !strings.Contains(err.Error(), "--stress-columns") {
+		t.Errorf("runStress() with no columns = %v, want an error about --stress-columns", err)
+	}
+}
+
+func TestHistogramPrecision(t *testing.T) {
+	for v := int64(1); v < 1<<30; v = v*3/2 + 1 {
+		index := bucketIndex(v)
+		high := bucketValue(index)
+		low := int64(0)
+		if index > 0 {
+			low = bucketValue(index-1) + 1
+		}
+		if v < low || v > high {
+			t.Fatalf("value %d is recorded in bucket %d of [%d, %d]", v, index, low, high)
+		}
+		if relative := float64(high-low) / float64(v); relative >= 0.01 {
+			t.Errorf("value %d is recorded in bucket [%d, %d] with an error of %.2f%%, want below 1%%", v, low, high, relative*100)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const metricsNamespace = "mysql_data_generator"
+
+// upper bounds (in seconds) of the buckets of the statement latency histogram
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// generatorMetrics holds the metrics exposed in the Prometheus text exposition format.
+// The metrics are kept in memory even when --metrics-addr is not provided so that the
+// workers do not need to care whether the endpoint is enabled.
+type generatorMetrics struct {
+	rowsInserted    int64
+	bytesWritten    int64
+	inflightWorkers int64
+	databaseSize    int64
+
+	mu        sync.Mutex
+	errors    map[string]int64
+	latencies map[statsKey]*promHistogram
+}
+
+// promHistogram is a cumulative histogram with fixed buckets as Prometheus expects
+type promHistogram struct {
+	buckets []int64
+	count   int64
+	sum     float64
+}
+
+var metrics = &generatorMetrics{
+	errors:    map[string]int64{},
+	latencies: map[statsKey]*promHistogram{},
+}
+
+// observe records the outcome of a statement
+func (m *generatorMetrics) observe(op, table string, latency time.Duration, rows, bytes int64, err error) {
+	if err != nil {
+		m.mu.Lock()
+		m.errors[errorCode(err)]++
+		m.mu.Unlock()
+		return
+	}
+	if op == OpInsert || op == OpTransaction {
+		atomic.AddInt64(&m.rowsInserted, rows)
+		atomic.AddInt64(&m.bytesWritten, bytes)
+	}
+
+	seconds := latency.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := statsKey{operation: op, table: table}
+	h, ok := m.latencies[key]
+	if !ok {
+		h = &promHistogram{buckets: make([]int64, len(latencyBuckets))}
+		m.latencies[key] = h
+	}
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (m *generatorMetrics) workerStarted() {
+	atomic.AddInt64(&m.inflightWorkers, 1)
+}
+
+func (m *generatorMetrics) workerStopped() {
+	atomic.AddInt64(&m.inflightWorkers, -1)
+}
+
+func (m *generatorMetrics) setDatabaseSize(size int) {
+	atomic.StoreInt64(&m.databaseSize, int64(size))
+}
+
+// errorCode returns the MySQL error number of the error or "unknown" for non MySQL errors
+func errorCode(err error) string {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return strconv.Itoa(int(mysqlErr.Number))
+	}
+	return "unknown"
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *generatorMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	var b strings.Builder
+	writeMetric(&b, "rows_inserted_total", "counter", "Total number of rows inserted.", atomic.LoadInt64(&m.rowsInserted))
+	writeMetric(&b, "bytes_written_total", "counter", "Total size in bytes of the values of the inserted rows, excluding the statement overhead.", atomic.LoadInt64(&m.bytesWritten))
+	writeMetric(&b, "inflight_workers", "gauge", "Number of workers currently running.", atomic.LoadInt64(&m.inflightWorkers))
+	writeMetric(&b, "database_size_bytes", "gauge", "Size of the database as reported by information_schema.", atomic.LoadInt64(&m.databaseSize))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(&b, "# HELP %s_errors_total Total number of failed statements by MySQL error code.\n", metricsNamespace)
+	fmt.Fprintf(&b, "# TYPE %s_errors_total counter\n", metricsNamespace)
+	codes := make([]string, 0, len(m.errors))
+	for code := range m.errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&b, "%s_errors_total{code=%q} %d\n", metricsNamespace, code, m.errors[code])
+	}
+
+	fmt.Fprintf(&b, "# HELP %s_statement_duration_seconds Latency of the statements.\n", metricsNamespace)
+	fmt.Fprintf(&b, "# TYPE %s_statement_duration_seconds histogram\n", metricsNamespace)
+	keys := make([]statsKey, 0, len(m.latencies))
+	for key := range m.latencies {
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+	for _, key := range keys {
+		h := m.latencies[key]
+		labels := fmt.Sprintf("operation=%q,table=%q", key.operation, key.table)
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(&b, "%s_statement_duration_seconds_bucket{%s,le=%q} %d\n", metricsNamespace, labels, strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(&b, "%s_statement_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", metricsNamespace, labels, h.count)
+		fmt.Fprintf(&b, "%s_statement_duration_seconds_sum{%s} %g\n", metricsNamespace, labels, h.sum)
+		fmt.Fprintf(&b, "%s_statement_duration_seconds_count{%s} %d\n", metricsNamespace, labels, h.count)
+	}
+
+	_, _ = w.Write([]byte(b.String()))
+}
+
+func writeMetric(b *strings.Builder, name, kind, help string, value int64) {
+	fmt.Fprintf(b, "# HELP %s_%s %s\n", metricsNamespace, name, help)
+	fmt.Fprintf(b, "# TYPE %s_%s %s\n", metricsNamespace, name, kind)
+	fmt.Fprintf(b, "%s_%s %d\n", metricsNamespace, name, value)
+}
+
+// serveMetrics starts the metrics endpoint in the background
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	go func() {
+		out.info("Serving metrics at %s/metrics", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			out.error(err, "Failed to serve metrics")
+		}
+	}()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// output formats
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// event kinds emitted in json output
+const (
+	EventPhase    = "phase"
+	EventLog      = "log"
+	EventError    = "error"
+	EventProgress = "progress"
+	EventStats    = "stats"
+	EventSummary  = "summary"
+	EventDBSizes  = "database_sizes"
+	EventOutage   = "outage"
+	EventOutages  = "outages"
+	EventLedger   = "ledger"
+	EventStress   = "stress"
+	EventSchema   = "schema"
+	EventPlan     = "plan"
+)
+
+// event holds the fields of a json event
+type event map[string]interface{}
+
+// eventWriter writes the output of the generator. In text mode, the messages are
+// written as human readable lines. In json mode, each message is written as a
+// single line json object so that the output can be parsed by other tools.
+type eventWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+var out = &eventWriter{w: os.Stdout}
+
+// setFormat sets the output format. It must be called before any output is written.
+func (e *eventWriter) setFormat(format string) error {
+	switch format {
+	case OutputText:
+		e.json = false
+	case OutputJSON:
+		e.json = true
+	default:
+		return fmt.Errorf("unknown output format %q. Expected one of (text, json)", format)
+	}
+	return nil
+}
+
+func (e *eventWriter) emit(kind string, fields event) {
+	fields["event"] = kind
+	fields["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		data, _ = json.Marshal(event{"event": EventError, "message": "failed to encode event", "error": err.Error()})
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(append(data, '\n'))
+}
+
+func (e *eventWriter) printf(format string, args ...interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = fmt.Fprintf(e.w, format, args...)
+}
+
+// text writes the message only in text mode. It is used for decorations like
+// headers that have no meaning in json output.
+func (e *eventWriter) text(format string, args ...interface{}) {
+	if !e.json {
+		e.printf(format, args...)
+	}
+}
+
+// phase reports that the generator has entered a new phase
+func (e *eventWriter) phase(phase, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if !e.json {
+		e.printf("%s\n", msg)
+		return
+	}
+	e.emit(EventPhase, event{"phase": phase, "message": msg})
+}
+
+// info reports an informational message
+func (e *eventWriter) info(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if !e.json {
+		e.printf("%s\n", msg)
+		return
+	}
+	e.emit(EventLog, event{"message": msg})
+}
+
+// error reports a failure along with the MySQL error code of the reason
+func (e *eventWriter) error(err error, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if !e.json {
+		e.printf("%s. Reason: %v.\n", msg, err)
+		return
+	}
+	e.emit(EventError, event{"message": msg, "error": err.Error(), "code": errorCode(err), "class": classifyError(err).name})
+}
+
+// progress reports the progress of the data insertion
+func (e *eventWriter) progress(percent float64, dataInserted, rowsInserted, curSize int, dbName string) {
+	if !e.json {
+		e.printf("Progress: %.2f%% Data Inserted: %s Current %q Size: %s\n", percent, formatSize(dataInserted), dbName, formatSize(curSize))
+		return
+	}
+	e.emit(EventProgress, event{
+		"percent":       percent,
+		"bytesInserted": dataInserted,
+		"rowsInserted":  rowsInserted,
+		"databaseSize":  curSize,
+		"database":      dbName,
+	})
+}
+
+// summary reports the final statistics of the run. The fields are printed as
+// "key: value" lines in text mode.
+func (e *eventWriter) summary(fields []summaryField) {
+	e.fields(EventSummary, fields)
+}
+
+// fields reports the fields as a single event of the given kind, or as "key: value"
+// lines in text mode
+func (e *eventWriter) fields(kind string, fields []summaryField) {
+	if !e.json {
+		for _, f := range fields {
+			e.printf("%35s: %s\n", f.title, f.text)
+		}
+		return
+	}
+	ev := event{}
+	for _, f := range fields {
+		ev[f.key] = f.value
+	}
+	e.emit(kind, ev)
+}
+
+// summaryField is a single field of the final summary
+type summaryField struct {
+	key   string
+	title string
+	text  string
+	value interface{}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// export format of the MySQL Shell dump layout
+const ExportShell = "shell"
+
+// compression of the chunks of the MySQL Shell dump
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+	CompressionNone = "none"
+)
+
+const (
+	// version of the server emulated by the offline outputs
+	emulatedServerVersion   = "8.0.27"
+	emulatedServerVersionID = 80027
+	// version of the dump format written by MySQL Shell 8.0.27
+	shellDumpVersion = "1.0.2"
+	// number of rows sampled to estimate the size of a row in a chunk
+	shellChunkSamples = 100
+)
+
+// shellChunk is a data file of a table of the MySQL Shell dump
+type shellChunk struct {
+	table int
+	index int
+	last  bool
+	// id of the first row and number of rows of the chunk
+	firstID int64
+	rows    int64
+}
+
+// shellChunkResult is the size of a written chunk
+type shellChunkResult struct {
+	file      string
+	dataBytes int64
+	fileBytes int64
+}
+
+// shellDumpFormat is the default dialect of util.dumpInstance
+var shellDumpFormat = generator.TSV
+
+// shellExtension returns the extension of the chunks for --export-compression
+func (opt *GeneratorOptions) shellExtension() (string, error) {
+	switch opt.exportCompression {
+	case CompressionZstd:
+		return "tsv.zst", nil
+	case CompressionGzip:
+		return "tsv.gz", nil
+	case CompressionNone:
+		return "tsv", nil
+	default:
+		return "", fmt.Errorf("unknown compression %q. Expected one of (zstd, gzip, none)", opt.exportCompression)
+	}
+}
+
+// shellBasename returns the name of an object as it is used in the file names
+// of the dump. The characters that are not allowed in a file name are percent
+// encoded like MySQL Shell does.
+func shellBasename(name string) string {
+	var b strings.Builder
+	for _, c := range []byte(name) {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '$' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// shellChunkRows returns the number of rows of a chunk of about --chunk-size uncompressed bytes
+func (opt *GeneratorOptions) shellChunkRows() (int64, error) {
+	chunkSize, err := (&GeneratorOptions{size: opt.chunkSize}).parseSize()
+	if err != nil {
+		return 0, fmt.Errorf("invalid chunk size %q. Reason: %v", opt.chunkSize, err)
+	}
+	r := rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	for i := 0; i < shellChunkSamples; i++ {
+		shellDumpFormat.WriteRow(&buf, generator.NewRow(r).Values())
+	}
+	rows := int64(chunkSize) * shellChunkSamples / int64(buf.Len())
+	if rows < 1 {
+		rows = 1
+	}
+	return rows, nil
+}
+
+// writeShellDump writes the rows to --export-dir in the dump layout of the
+// util.dumpInstance of MySQL Shell, so that the dump can be loaded with
+// util.loadDump. The tables are split into chunks of --chunk-size, which are
+// written by --concurrency workers in parallel. Each chunk is generated from its
+// own seed, so the dump is the same for the same seed regardless of the workers.
+func (opt *GeneratorOptions) writeShellDump() error {
+	if opt.exportDir == "" {
+		return fmt.Errorf("--export-format requires --export-dir")
+	}
+	extension, err := opt.shellExtension()
+	if err != nil {
+		return err
+	}
+	rows, err := opt.dumpRows()
+	if err != nil {
+		return err
+	}
+	chunkRows, err := opt.shellChunkRows()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(opt.exportDir, 0755); err != nil {
+		return err
+	}
+
+	startingTime := time.Now()
+	out.phase("exporting", "Writing MySQL Shell dump of database %q to %q.....", opt.dbName, opt.exportDir)
+	seed := opt.dumpSeed()
+	schema := shellBasename(opt.dbName)
+	tables := make([]string, len(rows))
+	basenames := map[string]string{}
+	for i := range rows {
+		tables[i] = fmt.Sprintf("table%d", i)
+		basenames[tables[i]] = shellBasename(tables[i])
+	}
+
+	if err := opt.writeShellMetadata(tables, basenames, extension); err != nil {
+		return err
+	}
+
+	var chunks []shellChunk
+	for i, count := range rows {
+		for index := int64(0); index == 0 || index*chunkRows < count; index++ {
+			chunk := shellChunk{table: i, index: int(index), firstID: index*chunkRows + 1, rows: chunkRows}
+			if remaining := count - index*chunkRows; remaining <= chunkRows {
+				chunk.rows, chunk.last = remaining, true
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	jobs := make(chan int)
+	results := make([]shellChunkResult, len(chunks))
+	// failed is closed on the first error so that no more chunks are written
+	failed := make(chan struct{})
+	var firstErr error
+	var once sync.Once
+	wg := sync.WaitGroup{}
+	for i := 0; i < opt.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				chunk := chunks[i]
+				result, err := opt.writeShellChunk(chunk, schema+"@"+basenames[tables[chunk.table]], extension, seed)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(failed)
+					})
+					return
+				}
+				results[i] = result
+			}
+		}()
+	}
+feed:
+	for i := range chunks {
+		select {
+		case jobs <- i:
+		case <-failed:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	var totalRows, dataBytes, fileBytes int64
+	tableDataBytes := map[string]int64{}
+	chunkFileBytes := map[string]int64{}
+	for i, chunk := range chunks {
+		totalRows += chunk.rows
+		dataBytes += results[i].dataBytes
+		fileBytes += results[i].fileBytes
+		tableDataBytes[tables[chunk.table]] += results[i].dataBytes
+		chunkFileBytes[results[i].file] = results[i].fileBytes
+	}
+	for i, count := range rows {
+		out.info("Exported %d rows of table %q", count, tables[i])
+	}
+
+	// util.loadDump refuses to load a dump without the done file
+	err = writeShellJSON(filepath.Join(opt.exportDir, "@.done.json"), map[string]interface{}{
+		"end":            time.Now().UTC().Format("2006-01-02 15:04:05"),
+		"dataBytes":      dataBytes,
+		"tableDataBytes": map[string]interface{}{opt.dbName: tableDataBytes},
+		"chunkFileBytes": chunkFileBytes,
+	})
+	if err != nil {
+		return err
+	}
+
+	out.text("\n=========================== Summery ===========================\n")
+	out.summary([]summaryField{
+		{key: "rowsInserted", title: "Total rows exported", text: strconv.FormatInt(totalRows, 10), value: totalRows},
+		{key: "chunks", title: "Chunks", text: strconv.Itoa(len(chunks)), value: len(chunks)},
+		{key: "dataBytes", title: "Uncompressed data size", text: formatSize(int(dataBytes)), value: dataBytes},
+		{key: "fileBytes", title: "Compressed data size", text: formatSize(int(fileBytes)), value: fileBytes},
+		{key: "durationSeconds", title: "Total time taken", text: time.Since(startingTime).String(), value: time.Since(startingTime).Seconds()},
+		{key: "seed", title: "Seed", text: strconv.FormatInt(seed, 10), value: seed},
+	})
+	return nil
+}
+
+// writeShellMetadata writes the metadata and the DDL files of the instance, the schema and the tables
+func (opt *GeneratorOptions) writeShellMetadata(tables []string, basenames map[string]string, extension string) error {
+	chunkSize, _ := (&GeneratorOptions{size: opt.chunkSize}).parseSize()
+	schema := shellBasename(opt.dbName)
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	err := writeShellJSON(filepath.Join(opt.exportDir, "@.json"), map[string]interface{}{
+		"dumper":                   "mysqlsh Ver " + emulatedServerVersion + " (mysql-data-generator)",
+		"version":                  shellDumpVersion,
+		"origin":                   "dumpInstance",
+		"schemas":                  []string{opt.dbName},
+		"basenames":                map[string]string{opt.dbName: schema},
+		"users":                    []string{},
+		"defaultCharacterSet":      "utf8mb4",
+		"tzUtc":                    true,
+		"bytesPerChunk":            chunkSize,
+		"user":                     "",
+		"hostname":                 "",
+		"server":                   "",
+		"serverVersion":            emulatedServerVersion,
+		"gtidExecuted":             "",
+		"gtidExecutedInconsistent": false,
+		"consistent":               true,
+		"mdsCompatibility":         false,
+		"begin":                    now,
+	})
+	if err != nil {
+		return err
+	}
+
+	header := fmt.Sprintf("-- MySQLShell dump %s  Distrib mysql-data-generator\n--\n-- Host: (offline)    Database: %s\n-- ------------------------------------------------------\n-- Server version\t%s\n", shellDumpVersion, opt.dbName, emulatedServerVersion)
+	files := map[string]string{
+		"@.sql":       header + generator.DumpHeader,
+		"@.post.sql":  header + generator.DumpFooter,
+		"@.users.sql": header + "\n-- no users have been dumped\n",
+		schema + ".sql": header + fmt.Sprintf("\nCREATE DATABASE /*!32312 IF NOT EXISTS*/ %s /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\n",
+			generator.QuoteIdentifier(opt.dbName)),
+	}
+	for _, table := range tables {
+		ddl := strings.Replace(generator.DumpTableStatement(table), "CREATE TABLE ", "CREATE TABLE IF NOT EXISTS ", 1)
+		files[schema+"@"+basenames[table]+".sql"] = header + "\n/*!40101 SET @saved_cs_client     = @@character_set_client */;\n/*!50503 SET character_set_client = utf8mb4 */;\n" +
+			ddl + ";\n/*!40101 SET character_set_client = @saved_cs_client */;\n"
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(opt.exportDir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	err = writeShellJSON(filepath.Join(opt.exportDir, schema+".json"), map[string]interface{}{
+		"schema":           opt.dbName,
+		"includesDdl":      true,
+		"includesViewsDdl": true,
+		"includesData":     true,
+		"tables":           tables,
+		"views":            []string{},
+		"events":           []string{},
+		"functions":        []string{},
+		"procedures":       []string{},
+		"basenames":        basenames,
+	})
+	if err != nil {
+		return err
+	}
+
+	compression := opt.exportCompression
+	for _, table := range tables {
+		err := writeShellJSON(filepath.Join(opt.exportDir, schema+"@"+basenames[table]+".json"), map[string]interface{}{
+			"options": map[string]interface{}{
+				"schema":                   opt.dbName,
+				"table":                    table,
+				"columns":                  generator.Columns,
+				"fieldsTerminatedBy":       shellDumpFormat.Delimiter,
+				"fieldsEnclosedBy":         shellDumpFormat.Quote,
+				"fieldsOptionallyEnclosed": false,
+				"fieldsEscapedBy":          `\`,
+				"linesTerminatedBy":        shellDumpFormat.LineTerminator,
+			},
+			"primaryIndex": []string{"id"},
+			"compression":  compression,
+			"extension":    extension,
+			"chunking":     true,
+			"includesData": true,
+			"includesDdl":  true,
+			"triggers":     []string{},
+			"histograms":   []string{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeShellChunk writes the rows of a chunk along with its index file. The name
+// of the last chunk of a table has a double @ (i.e. db@table@@3.tsv.zst). The index
+// file holds the offset of the end of each row of the uncompressed data as a big
+// endian 64 bit integer, so the last entry is the size of the uncompressed data.
+func (opt *GeneratorOptions) writeShellChunk(chunk shellChunk, prefix, extension string, seed int64) (shellChunkResult, error) {
+	separator := "@"
+	if chunk.last {
+		separator = "@@"
+	}
+	name := fmt.Sprintf("%s%s%d.%s", prefix, separator, chunk.index, extension)
+	path := filepath.Join(opt.exportDir, name)
+	f, err := createOutputFile(path)
+	if err != nil {
+		return shellChunkResult{}, err
+	}
+	idx, err := createOutputFile(path + ".idx")
+	if err != nil {
+		f.Close()
+		return shellChunkResult{}, err
+	}
+
+	r := rand.New(rand.NewSource(generator.BatchSeed(seed, chunk.table, int64(chunk.index))))
+	var buf bytes.Buffer
+	var offset int64
+	var entry [8]byte
+	for id := chunk.firstID; id < chunk.firstID+chunk.rows; id++ {
+		row := generator.NewRow(r)
+		row.ID = id
+		buf.Reset()
+		shellDumpFormat.WriteRow(&buf, row.Values())
+		n, err := f.Write(buf.Bytes())
+		if err != nil {
+			f.Close()
+			idx.Close()
+			return shellChunkResult{}, err
+		}
+		offset += int64(n)
+		binary.BigEndian.PutUint64(entry[:], uint64(offset))
+		idx.Write(entry[:])
+	}
+	if err := f.Close(); err != nil {
+		idx.Close()
+		return shellChunkResult{}, fmt.Errorf("failed to write chunk %q. Reason: %v", name, err)
+	}
+	if err := idx.Close(); err != nil {
+		return shellChunkResult{}, fmt.Errorf("failed to write index of chunk %q. Reason: %v", name, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return shellChunkResult{}, err
+	}
+	return shellChunkResult{file: name, dataBytes: offset, fileBytes: info.Size()}, nil
+}
+
+// writeShellJSON writes a metadata file of the dump
+func writeShellJSON(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// exit status of a run stopped by a signal. It follows the shell convention of 128 + signal number.
+const (
+	ExitInterrupted = 130 // SIGINT
+	ExitTerminated  = 143 // SIGTERM
+)
+
+// interruptedError is returned by a run that has been stopped by a signal after
+// the workers have finished their current batch
+type interruptedError struct {
+	signal os.Signal
+}
+
+func (e *interruptedError) Error() string {
+	return fmt.Sprintf("interrupted by %s", e.signal)
+}
+
+// exitCode returns the exit status of the process for the signal
+func (e *interruptedError) exitCode() int {
+	if e.signal == syscall.SIGTERM {
+		return ExitTerminated
+	}
+	return ExitInterrupted
+}
+
+// shutdownHandler cancels the run on SIGINT or SIGTERM so that the workers stop
+// after their current batch. A second signal exits immediately.
+type shutdownHandler struct {
+	mu       sync.Mutex
+	received os.Signal
+	signals  chan os.Signal
+	done     chan struct{}
+}
+
+func notifyShutdown(cancel context.CancelFunc) *shutdownHandler {
+	h := &shutdownHandler{
+		signals: make(chan os.Signal, 2),
+		done:    make(chan struct{}),
+	}
+	signal.Notify(h.signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for {
+			select {
+			case <-h.done:
+				return
+			case sig := <-h.signals:
+				h.mu.Lock()
+				first := h.received == nil
+				if first {
+					h.received = sig
+				}
+				h.mu.Unlock()
+				if !first {
+					out.info("Received %s again. Exiting without waiting for the workers", sig)
+					os.Exit((&interruptedError{signal: sig}).exitCode())
+				}
+				out.phase("interrupted", "Received %s. Waiting for the workers to finish their current batch.....", sig)
+				cancel()
+			}
+		}
+	}()
+	return h
+}
+
+// stop stops listening for the signals
+func (h *shutdownHandler) stop() {
+	signal.Stop(h.signals)
+	close(h.done)
+}
+
+// interrupted returns a non-nil error if the run has been stopped by a signal
+func (h *shutdownHandler) interrupted() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.received == nil {
+		return nil
+	}
+	return &interruptedError{signal: h.received}
+}
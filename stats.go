@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// number of sub-buckets per power of two. The values above subBucketCount fall in
+	// subBucketHalf buckets per power of two, so 256 sub-buckets keep the error of the
+	// recorded values below 1/128 (two significant digits), similar to a HDR histogram.
+	subBucketBits  = 8
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+
+	// placeholder table name used for the aggregated statistics
+	allTables = "*"
+
+	// scopes of the statistics report
+	StatsScopeInterval = "interval"
+	StatsScopeTotal    = "total"
+)
+
+// histogram is a log-linear latency histogram in the spirit of HDR histogram.
+// Values are recorded in microseconds. Values smaller than subBucketCount are
+// recorded exactly and the larger values are recorded with a fixed relative precision.
+type histogram struct {
+	counts []int64
+	total  int64
+	max    int64
+}
+
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	return subBucketCount + (shift-1)*subBucketHalf + int(v>>uint(shift)) - subBucketHalf
+}
+
+// bucketValue returns the highest value that is recorded in the bucket
+func bucketValue(index int) int64 {
+	if index < subBucketCount {
+		return int64(index)
+	}
+	shift := (index-subBucketCount)/subBucketHalf + 1
+	sub := int64((index-subBucketCount)%subBucketHalf + subBucketHalf)
+	return (sub+1)<<uint(shift) - 1
+}
+
+func (h *histogram) record(latency time.Duration) {
+	v := latency.Microseconds()
+	if v < 0 {
+		v = 0
+	}
+	idx := bucketIndex(v)
+	if idx >= len(h.counts) {
+		counts := make([]int64, idx+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[idx]++
+	h.total++
+	if v > h.max {
+		h.max = v
+	}
+}
+
+func (h *histogram) merge(other *histogram) {
+	if len(other.counts) > len(h.counts) {
+		counts := make([]int64, len(other.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.total += other.total
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// quantile returns the latency at the given quantile (i.e. 0.99 for p99)
+func (h *histogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	target := int64(q*float64(h.total) + 0.5)
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= target {
+			v := bucketValue(i)
+			if v > h.max {
+				v = h.max
+			}
+			return time.Duration(v) * time.Microsecond
+		}
+	}
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// statsKey identifies the statistics of an operation on a table
+type statsKey struct {
+	operation string
+	table     string
+}
+
+// operationStats holds the statistics of an operation on a table
+type operationStats struct {
+	latency histogram
+	rows    int64
+	bytes   int64
+	errors  int64
+}
+
+func (s *operationStats) merge(other *operationStats) {
+	s.latency.merge(&other.latency)
+	s.rows += other.rows
+	s.bytes += other.bytes
+	s.errors += other.errors
+}
+
+// statsRecorder records the statistics of a single worker. Each worker has its own
+// recorder so that the workers do not contend on a shared lock.
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats map[statsKey]*operationStats
+}
+
+func (r *statsRecorder) record(op, table string, latency time.Duration, rows, bytes int64, err error) {
+	metrics.observe(op, table, latency, rows, bytes, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := statsKey{operation: op, table: table}
+	stats, ok := r.stats[key]
+	if !ok {
+		stats = &operationStats{}
+		r.stats[key] = stats
+	}
+	if err != nil {
+		stats.errors++
+		return
+	}
+	stats.latency.record(latency)
+	stats.rows += rows
+	stats.bytes += bytes
+}
+
+// statsCollector merges the statistics of all the workers
+type statsCollector struct {
+	mu        sync.Mutex
+	recorders []*statsRecorder
+	total     map[statsKey]*operationStats
+	start     time.Time
+	lastFlush time.Time
+}
+
+func newStatsCollector() *statsCollector {
+	now := time.Now()
+	return &statsCollector{
+		total:     map[statsKey]*operationStats{},
+		start:     now,
+		lastFlush: now,
+	}
+}
+
+// newRecorder returns a recorder for a new worker
+func (c *statsCollector) newRecorder() *statsRecorder {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := &statsRecorder{stats: map[statsKey]*operationStats{}}
+	c.recorders = append(c.recorders, r)
+	return r
+}
+
+// flush collects the statistics recorded by the workers since the last flush and
+// returns them along with the length of the interval. The collected statistics are
+// added to the overall statistics too.
+func (c *statsCollector) flush() (map[statsKey]*operationStats, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	interval := map[statsKey]*operationStats{}
+	for _, r := range c.recorders {
+		r.mu.Lock()
+		stats := r.stats
+		r.stats = map[statsKey]*operationStats{}
+		r.mu.Unlock()
+		mergeStats(interval, stats)
+	}
+	mergeStats(c.total, interval)
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastFlush)
+	c.lastFlush = now
+	return interval, elapsed
+}
+
+// summary flushes the pending statistics and returns the overall statistics
+func (c *statsCollector) summary() (map[statsKey]*operationStats, time.Duration) {
+	c.flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total, c.lastFlush.Sub(c.start)
+}
+
+func mergeStats(dst, src map[statsKey]*operationStats) {
+	for key, stats := range src {
+		cur, ok := dst[key]
+		if !ok {
+			cur = &operationStats{}
+			dst[key] = cur
+		}
+		cur.merge(stats)
+	}
+}
+
+// reportStats prints the statistics of the last interval periodically until the context is cancelled
+func (c *statsCollector) reportStats(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, elapsed := c.flush()
+			out.text("\n------------------- Statistics of last %s -------------------\n", elapsed.Round(time.Second).String())
+			printStats(StatsScopeInterval, stats, elapsed)
+		}
+	}
+}
+
+// statsLine is a single line of the statistics report
+type statsLine struct {
+	Operation   string  `json:"operation"`
+	Table       string  `json:"table"`
+	Count       int64   `json:"count"`
+	Errors      int64   `json:"errors"`
+	RowsPerSec  float64 `json:"rowsPerSecond"`
+	BytesPerSec float64 `json:"bytesPerSecond"`
+	P50         float64 `json:"p50Ms"`
+	P95         float64 `json:"p95Ms"`
+	P99         float64 `json:"p99Ms"`
+	P999        float64 `json:"p999Ms"`
+	Max         float64 `json:"maxMs"`
+}
+
+// printStats prints the statistics per operation and table followed by the
+// statistics per operation across all tables and the overall statistics.
+func printStats(scope string, stats map[statsKey]*operationStats, elapsed time.Duration) {
+	lines := statsLines(stats, elapsed)
+	if out.json {
+		out.emit(EventStats, event{"scope": scope, "elapsedSeconds": elapsed.Seconds(), "operations": lines})
+		return
+	}
+	if len(lines) == 0 {
+		out.printf("No operation has been performed\n")
+		return
+	}
+	out.printf("%-10s %-12s %10s %8s %10s %12s %10s %10s %10s %10s %10s\n",
+		"Operation", "Table", "Count", "Errors", "Rows/s", "Bytes/s", "p50", "p95", "p99", "p99.9", "Max")
+	for _, l := range lines {
+		out.printf("%-10s %-12s %10d %8d %10.2f %12s %10s %10s %10s %10s %10s\n",
+			l.Operation,
+			l.Table,
+			l.Count,
+			l.Errors,
+			l.RowsPerSec,
+			formatSize(int(l.BytesPerSec)),
+			formatLatency(msToDuration(l.P50)),
+			formatLatency(msToDuration(l.P95)),
+			formatLatency(msToDuration(l.P99)),
+			formatLatency(msToDuration(l.P999)),
+			formatLatency(msToDuration(l.Max)),
+		)
+	}
+}
+
+func statsLines(stats map[statsKey]*operationStats, elapsed time.Duration) []statsLine {
+	perOperation := map[statsKey]*operationStats{}
+	overall := &operationStats{}
+	keys := make([]statsKey, 0, len(stats))
+	for key, s := range stats {
+		keys = append(keys, key)
+		opKey := statsKey{operation: key.operation, table: allTables}
+		if _, ok := perOperation[opKey]; !ok {
+			perOperation[opKey] = &operationStats{}
+		}
+		perOperation[opKey].merge(s)
+		overall.merge(s)
+	}
+	sortKeys(keys)
+	opKeys := make([]statsKey, 0, len(perOperation))
+	for key := range perOperation {
+		opKeys = append(opKeys, key)
+	}
+	sortKeys(opKeys)
+
+	lines := make([]statsLine, 0, len(keys)+len(opKeys)+1)
+	for _, key := range keys {
+		lines = append(lines, newStatsLine(key.operation, key.table, stats[key], elapsed))
+	}
+	if len(keys) > len(opKeys) {
+		for _, key := range opKeys {
+			lines = append(lines, newStatsLine(key.operation, key.table, perOperation[key], elapsed))
+		}
+	}
+	if len(opKeys) > 1 {
+		lines = append(lines, newStatsLine("all", allTables, overall, elapsed))
+	}
+	return lines
+}
+
+func newStatsLine(op, table string, s *operationStats, elapsed time.Duration) statsLine {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	return statsLine{
+		Operation:   op,
+		Table:       table,
+		Count:       s.latency.total,
+		Errors:      s.errors,
+		RowsPerSec:  float64(s.rows) / seconds,
+		BytesPerSec: float64(s.bytes) / seconds,
+		P50:         durationToMs(s.latency.quantile(0.50)),
+		P95:         durationToMs(s.latency.quantile(0.95)),
+		P99:         durationToMs(s.latency.quantile(0.99)),
+		P999:        durationToMs(s.latency.quantile(0.999)),
+		Max:         durationToMs(time.Duration(s.latency.max) * time.Microsecond),
+	}
+}
+
+func durationToMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func sortKeys(keys []statsKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].table < keys[j].table
+	})
+}
+
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	case d < time.Second:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Millisecond).String()
+	}
+}
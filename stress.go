@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stress step results
+const (
+	StressOK       = "ok"
+	StressRejected = "rejected"
+	StressStalled  = "stalled"
+	StressSkipped  = "skipped"
+
+	// size of the rows the huge transactions are built from
+	stressTxnRowSize = OneMB
+
+	stressLargeRowTable = "stress_large_rows"
+	stressWideTable     = "stress_wide_rows"
+)
+
+// server variables that limit the size of rows and transactions
+var stressVariables = []string{
+	"max_allowed_packet",
+	"binlog_cache_size",
+	"max_binlog_cache_size",
+	"binlog_row_image",
+	"group_replication_transaction_size_limit",
+	"replica_max_allowed_packet",
+	"slave_max_allowed_packet",
+}
+
+// stressResult is the outcome of a single step of the stress profile
+type stressResult struct {
+	step     string
+	size     int
+	status   string
+	duration time.Duration
+	err      error
+	replica  string
+}
+
+// runStress runs the stress profile. It produces rows close to max_allowed_packet,
+// huge transactions and rows of tables with many wide columns, and reports which of
+// them have been rejected by the server or stalled on the server or the replica.
+func (opt *GeneratorOptions) runStress() error {
+	if opt.stressColumns < 1 {
+		return fmt.Errorf("invalid --stress-columns %d. The wide table needs at least one column", opt.stressColumns)
+	}
+	txnSizes, err := parseSizeList(opt.stressTxnSizes)
+	if err != nil {
+		return err
+	}
+	if err := opt.ensureDatabase(); err != nil {
+		return err
+	}
+	// let the driver use the max_allowed_packet of the server instead of its own default
+	db, err = opt.getClient(opt.dbName, func(cfg *mysql.Config) {
+		cfg.MaxAllowedPacket = 0
+	})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+
+	var replica *sql.DB
+	if opt.replicaHost != "" {
+		replica, err = opt.getClient(opt.dbName, func(cfg *mysql.Config) {
+			cfg.Net = "tcp"
+			cfg.Addr = net.JoinHostPort(opt.replicaHost, strconv.Itoa(opt.port))
+		})
+		if err != nil {
+			return err
+		}
+		defer replica.Close()
+	}
+
+	out.phase("stress-limits", "Reading server limits.....")
+	limits, err := readServerVariables(stressVariables)
+	if err != nil {
+		return err
+	}
+	for _, name := range stressVariables {
+		if value, ok := limits[name]; ok {
+			out.info("%40s: %s", name, value)
+		}
+	}
+	maxAllowedPacket, err := strconv.Atoi(limits["max_allowed_packet"])
+	if err != nil {
+		return fmt.Errorf("failed to read max_allowed_packet. Reason: %v", err)
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id bigint NOT NULL AUTO_INCREMENT PRIMARY KEY, payload LONGTEXT)", stressLargeRowTable),
+		newWideTableStatement(opt.stressColumns),
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create stress table. Reason: %v", err)
+		}
+	}
+
+	var results []stressResult
+
+	// rows close to and above max_allowed_packet. the statement overhead is subtracted
+	// so that the largest row that should be accepted fits in a single packet.
+	out.phase("stress-large-rows", "Inserting rows close to max_allowed_packet (%s).....", formatSize(maxAllowedPacket))
+	for _, size := range largeRowSizes(maxAllowedPacket) {
+		results = append(results, opt.stressStep(replica, fmt.Sprintf("large row (%s)", formatSize(size)), size, func() (int64, error) {
+			return insertLargeRows(nil, 1, size)
+		}))
+	}
+
+	// huge single transactions
+	out.phase("stress-huge-txn", "Inserting huge transactions.....")
+	for _, size := range txnSizes {
+		rows := size / stressTxnRowSize
+		if rows < 1 {
+			rows = 1
+		}
+		results = append(results, opt.stressStep(replica, fmt.Sprintf("transaction (%s)", formatSize(size)), size, func() (int64, error) {
+			tx, err := db.Begin()
+			if err != nil {
+				return 0, err
+			}
+			id, err := insertLargeRows(tx, rows, stressTxnRowSize)
+			if err != nil {
+				_ = tx.Rollback()
+				return 0, err
+			}
+			return id, tx.Commit()
+		}))
+	}
+
+	// rows of a table with many wide columns
+	out.phase("stress-wide-rows", "Inserting rows with %d wide columns.....", opt.stressColumns)
+	for _, width := range []int{255, OneKB, 8 * OneKB} {
+		size := width * opt.stressColumns
+		results = append(results, opt.stressStep(replica, fmt.Sprintf("wide row (%d x %s)", opt.stressColumns, formatSize(width)), size, func() (int64, error) {
+			return insertWideRow(opt.stressColumns, width)
+		}))
+	}
+
+	printStressResults(results)
+	return nil
+}
+
+// stressStep runs a single step and reports whether it has been rejected or has
+// stalled on the server or on the replica. fn returns the id of the last row written.
+func (opt *GeneratorOptions) stressStep(replica *sql.DB, step string, size int, fn func() (int64, error)) stressResult {
+	result := stressResult{step: step, size: size, replica: StressSkipped}
+	out.info("Running step: %s", step)
+
+	start := time.Now()
+	id, err := fn()
+	result.duration = time.Since(start)
+	switch {
+	case err != nil:
+		result.status = StressRejected
+		result.err = err
+		return result
+	case result.duration > opt.stallThreshold:
+		result.status = StressStalled
+	default:
+		result.status = StressOK
+	}
+
+	if replica != nil {
+		result.replica = opt.waitForReplica(replica, step, id)
+	}
+	return result
+}
+
+// waitForReplica waits until the row with the given id of the step's table has been
+// applied by the replica. It returns the replication status of the step.
+func (opt *GeneratorOptions) waitForReplica(replica *sql.DB, step string, id int64) string {
+	table := stressLargeRowTable
+	if strings.HasPrefix(step, "wide row") {
+		table = stressWideTable
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opt.stallThreshold)
+	defer cancel()
+	for {
+		var count int
+		err := replica.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id = %d", table, id)).Scan(&count)
+		if err == nil && count > 0 {
+			return StressOK
+		}
+		select {
+		case <-ctx.Done():
+			if reason := replicationError(replica); reason != "" {
+				return fmt.Sprintf("%s (%s)", StressRejected, reason)
+			}
+			return StressStalled
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// replicationError returns the last error of the replication threads, if any
+func replicationError(replica *sql.DB) string {
+	for _, statement := range []string{"SHOW REPLICA STATUS", "SHOW SLAVE STATUS"} {
+		rows, err := replica.Query(statement)
+		if err != nil {
+			continue
+		}
+		status, err := scanRow(rows)
+		if err != nil {
+			return ""
+		}
+		for _, field := range []string{"Last_SQL_Error", "Last_IO_Error"} {
+			if status[field] != "" {
+				return status[field]
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// scanRow returns the first row of the result as a column name to value map and closes the rows
+func scanRow(rows *sql.Rows) (map[string]string, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]string{}
+	if !rows.Next() {
+		return result, rows.Err()
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	for i, column := range columns {
+		result[column] = string(values[i])
+	}
+	return result, nil
+}
+
+// readServerVariables returns the values of the given global variables. Variables
+// that do not exist in the server are omitted.
+func readServerVariables(names []string) (map[string]string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("'%s'", name)
+	}
+	rows, err := db.Query(fmt.Sprintf("SHOW GLOBAL VARIABLES WHERE Variable_name IN (%s)", strings.Join(quoted, ",")))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	variables := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		variables[name] = value
+	}
+	return variables, rows.Err()
+}
+
+// largeRowSizes returns row sizes growing up to max_allowed_packet along with one
+// size right below and one right above it
+func largeRowSizes(maxAllowedPacket int) []int {
+	var sizes []int
+	for size := OneMB; size < maxAllowedPacket/2; size *= 4 {
+		sizes = append(sizes, size)
+	}
+	return append(sizes, maxAllowedPacket-OneKB, maxAllowedPacket+OneKB)
+}
+
+// insertLargeRows inserts rows with a payload of the given size and returns the id
+// of the last row. The rows are inserted in the transaction if it is not nil.
+func insertLargeRows(tx *sql.Tx, rows, size int) (int64, error) {
+	// the payload is written as a string literal so that the packet sent to the
+	// server is as large as the row itself
+	statement := fmt.Sprintf("INSERT INTO %s (payload) VALUES ('%s')", stressLargeRowTable, strings.Repeat("x", size-64))
+	var id int64
+	for i := 0; i < rows; i++ {
+		var res sql.Result
+		var err error
+		if tx != nil {
+			res, err = tx.Exec(statement)
+		} else {
+			res, err = db.Exec(statement)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+func newWideTableStatement(columns int) string {
+	definitions := make([]string, columns)
+	for i := range definitions {
+		definitions[i] = fmt.Sprintf("c%d TEXT", i)
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id bigint NOT NULL AUTO_INCREMENT PRIMARY KEY, %s)", stressWideTable, strings.Join(definitions, ", "))
+}
+
+// insertWideRow inserts a row with every column filled with a value of the given width
+func insertWideRow(columns, width int) (int64, error) {
+	names := make([]string, columns)
+	values := make([]string, columns)
+	value := fmt.Sprintf("'%s'", strings.Repeat("w", width))
+	for i := range names {
+		names[i] = fmt.Sprintf("c%d", i)
+		values[i] = value
+	}
+	res, err := db.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", stressWideTable, strings.Join(names, ","), strings.Join(values, ",")))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// parseSizeList parses a comma separated list of sizes (i.e. 64MB,256MB,1GB)
+func parseSizeList(list string) ([]int, error) {
+	var sizes []int
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		size, err := (&GeneratorOptions{size: item}).parseSize()
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q. Reason: %v", item, err)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func printStressResults(results []stressResult) {
+	if out.json {
+		for _, r := range results {
+			ev := event{
+				"step":            r.step,
+				"sizeBytes":       r.size,
+				"status":          r.status,
+				"durationSeconds": r.duration.Seconds(),
+				"replica":         r.replica,
+			}
+			if r.err != nil {
+				ev["error"] = r.err.Error()
+				ev["code"] = errorCode(r.err)
+			}
+			out.emit(EventStress, ev)
+		}
+		return
+	}
+
+	out.printf("\n=========================== Stress Results ===========================\n")
+	out.printf("%-32s %-10s %-12s %-10s %s\n", "Step", "Status", "Duration", "Code", "Replica")
+	for _, r := range results {
+		code := "-"
+		if r.err != nil {
+			code = errorCode(r.err)
+		}
+		out.printf("%-32s %-10s %-12s %-10s %s\n", r.step, r.status, r.duration.Round(time.Millisecond).String(), code, r.replica)
+	}
+	for _, r := range results {
+		if r.err != nil {
+			out.printf("%s: %v\n", r.step, r.err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rate units accepted by the --rate flag
+const (
+	RateRows  = "rows"
+	RateBytes = "bytes"
+)
+
+// tokenBucket is a simple token bucket shared by all the insert workers. Tokens are
+// refilled continuously at the configured rate and a worker has to take as many tokens
+// as the cost of the statement it is going to execute (one per row or one per byte).
+type tokenBucket struct {
+	mu       sync.Mutex
+	unit     string
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// parseRate parses the --rate flag. The accepted forms are "<n>rows/s" and "<n><KB|MB|GB>/s".
+func parseRate(rate string) (*tokenBucket, error) {
+	var amount float64
+	var unit string
+	if _, err := fmt.Sscanf(rate, "%f%s", &amount, &unit); err != nil {
+		return nil, fmt.Errorf("invalid rate %q. Reason: %v", rate, err)
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("invalid rate %q. Rate must be greater than zero", rate)
+	}
+	if !strings.HasSuffix(unit, "/s") {
+		return nil, fmt.Errorf("invalid rate %q. Expected rate to be per second (i.e. 100rows/s or 5MB/s)", rate)
+	}
+
+	bucket := &tokenBucket{last: time.Now()}
+	switch strings.TrimSuffix(unit, "/s") {
+	case "rows", "row":
+		bucket.unit = RateRows
+		bucket.rate = amount
+	case "KB", "K":
+		bucket.unit = RateBytes
+		bucket.rate = amount * OneKB
+	case "MB", "Mi":
+		bucket.unit = RateBytes
+		bucket.rate = amount * OneMB
+	case "GB", "Gi":
+		bucket.unit = RateBytes
+		bucket.rate = amount * OneGB
+	default:
+		return nil, fmt.Errorf("expected rate unit to one of (rows/s, KB/s, MB/s, GB/s). Found: %s", unit)
+	}
+	// allow bursts of up to one second worth of tokens
+	bucket.capacity = bucket.rate
+	bucket.tokens = bucket.capacity
+	return bucket, nil
+}
+
+// wait blocks until n tokens are available or the context is cancelled.
+// A request larger than the bucket capacity is allowed once the bucket is full,
+// otherwise a single large row could block forever.
+func (b *tokenBucket) wait(ctx context.Context, n float64) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+
+		need := n
+		if need > b.capacity {
+			need = b.capacity
+		}
+		if b.tokens >= need {
+			b.tokens -= n
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// cost returns the number of tokens required to execute the given statement.
+func (b *tokenBucket) cost(statement string) float64 {
+	if b.unit == RateBytes {
+		return float64(len(statement))
+	}
+	return 1
+}
+
+// batchCost returns the number of tokens required to write a batch of rows with
+// values of the given size
+func (b *tokenBucket) batchCost(rows, bytes int64) float64 {
+	if b.unit == RateBytes {
+		return float64(bytes)
+	}
+	return float64(rows)
+}
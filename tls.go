@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// TLS modes. They follow the --ssl-mode semantics of the MySQL client.
+const (
+	TLSDisabled       = "disabled"
+	TLSPreferred      = "preferred"
+	TLSRequired       = "required"
+	TLSVerifyCA       = "verify-ca"
+	TLSVerifyIdentity = "verify-identity"
+
+	// name the TLS configuration is registered with in the driver
+	tlsConfigName = "generator"
+)
+
+// environment variables holding the PEM encoded certificates when the files are not provided
+const (
+	envCACert     = "CA_CERT"
+	envClientCert = "CLIENT_CERT"
+	envClientKey  = "CLIENT_KEY"
+)
+
+// tlsMode returns the TLS mode of the connections. An empty mode keeps the tls
+// parameter of --dsn as is.
+func (opt *GeneratorOptions) tlsMode() (string, error) {
+	switch mode := strings.ToLower(opt.tlsModeName); mode {
+	case TLSDisabled, TLSPreferred, TLSRequired, TLSVerifyCA, TLSVerifyIdentity:
+		return mode, nil
+	case "":
+		switch {
+		case opt.requireTLS:
+			return TLSVerifyIdentity, nil
+		case opt.dsn != "":
+			return "", nil
+		default:
+			return TLSDisabled, nil
+		}
+	default:
+		return "", fmt.Errorf("unknown tls mode %q. Expected one of (disabled, preferred, required, verify-ca, verify-identity)", opt.tlsModeName)
+	}
+}
+
+// configureTLS sets the TLS configuration of the driver configuration according to
+// the TLS mode. The certificates are loaded in memory and never written to the disk.
+func (opt *GeneratorOptions) configureTLS(cfg *mysql.Config) error {
+	mode, err := opt.tlsMode()
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case "":
+		return nil
+	case TLSDisabled:
+		cfg.TLSConfig = "false"
+		return nil
+	case TLSPreferred:
+		// the driver falls back to an unencrypted connection only for its builtin
+		// preferred configuration, which does not send the client certificate
+		cfg.TLSConfig = TLSPreferred
+		return nil
+	}
+
+	caCert, err := readPEM(opt.caCert, envCACert)
+	if err != nil {
+		return err
+	}
+	clientCert, err := readPEM(opt.clientCert, envClientCert)
+	if err != nil {
+		return err
+	}
+	clientKey, err := readPEM(opt.clientKey, envClientKey)
+	if err != nil {
+		return err
+	}
+
+	config := &tls.Config{ServerName: opt.tlsServerName}
+	if len(clientCert) > 0 || len(clientKey) > 0 {
+		cert, err := tls.X509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate. Reason: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if len(caCert) > 0 {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caCert) {
+			return fmt.Errorf("failed to parse CA certificate")
+		}
+	}
+
+	switch mode {
+	case TLSRequired:
+		config.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		if config.RootCAs == nil {
+			return fmt.Errorf("tls mode %s requires a CA certificate", TLSVerifyCA)
+		}
+		// verify the certificate chain but not the host name
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyChain(config.RootCAs)
+	}
+
+	if err := mysql.RegisterTLSConfig(tlsConfigName, config); err != nil {
+		return err
+	}
+	cfg.TLSConfig = tlsConfigName
+	return nil
+}
+
+// verifyChain returns a function that verifies the certificate chain of the server
+// against the CA without checking the host name
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server has not provided a certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
+
+// readPEM returns the content of the file, or of the environment variable if no file is provided
+func readPEM(path, env string) ([]byte, error) {
+	if path == "" {
+		return []byte(os.Getenv(env)), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q. Reason: %v", path, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"strings"
+)
+
+// OpTransaction is the operation reported in the statistics for a transaction of multiple inserts
+const OpTransaction = "txn"
+
+// parseIsolationLevel parses the --isolation-level flag. An empty level means
+// the default isolation level of the server.
+func parseIsolationLevel(level string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.ReplaceAll(level, "_", "-")) {
+	case "":
+		return sql.LevelDefault, nil
+	case "read-uncommitted":
+		return sql.LevelReadUncommitted, nil
+	case "read-committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable-read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level %q. Expected one of (read-uncommitted, read-committed, repeatable-read, serializable)", level)
+	}
+}
+
+// parseMultiTableTxn applies the deprecated --multi-table-txn, which makes every
+// transaction a multi-table transaction. The ratio is validated by the generator.
+func (opt *GeneratorOptions) parseMultiTableTxn() {
+	if opt.multiTableTxn && !isFlagSet("multi-table-txn-ratio") {
+		opt.multiTableTxnRatio = 1
+	}
+}
+
+// batchStats returns the operation and the table of a batch for the statistics
+func batchStats(batch generator.Batch) (string, string) {
+	op := OpInsert
+	if batch.Rows > 1 {
+		op = OpTransaction
+	}
+	table := allTables
+	if len(batch.Tables) == 1 {
+		table = batch.Tables[0].Table
+	}
+	return op, table
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/hossainemruz/mysql-data-generator/generator"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// operations performed in workload mode
+const (
+	OpSelect = "select"
+	OpRange  = "range"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpInsert = "insert"
+
+	defaultWorkloadMix = "select:40,range:10,update:25,delete:5,insert:20"
+
+	// number of rows read by a range scan
+	rangeScanSize = 100
+)
+
+var workloadOperations = []string{OpSelect, OpRange, OpUpdate, OpDelete, OpInsert}
+
+type weightedOperation struct {
+	name   string
+	weight int
+}
+
+// workloadMix picks operations randomly according to their weights
+type workloadMix struct {
+	operations  []weightedOperation
+	totalWeight int
+}
+
+// parseWorkloadMix parses the --workload-mix flag. The expected format is "<operation>:<weight>,..."
+func parseWorkloadMix(mix string) (*workloadMix, error) {
+	w := &workloadMix{}
+	for _, entry := range strings.Split(mix, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid workload mix entry %q. Expected format: <operation>:<weight>", entry)
+		}
+		name := strings.TrimSpace(parts[0])
+		if !isValidOperation(name) {
+			return nil, fmt.Errorf("unknown workload operation %q. Expected one of (%s)", name, strings.Join(workloadOperations, ", "))
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight for workload operation %q. Weight must be a non-negative integer", name)
+		}
+		if weight == 0 {
+			continue
+		}
+		w.operations = append(w.operations, weightedOperation{name: name, weight: weight})
+		w.totalWeight += weight
+	}
+	if w.totalWeight == 0 {
+		return nil, fmt.Errorf("workload mix %q does not contain any operation with non-zero weight", mix)
+	}
+	return w, nil
+}
+
+func isWriteOperation(op string) bool {
+	return op == OpUpdate || op == OpDelete || op == OpInsert
+}
+
+func isValidOperation(name string) bool {
+	for _, op := range workloadOperations {
+		if op == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *workloadMix) pick(r *rand.Rand) string {
+	n := r.Intn(w.totalWeight)
+	for _, op := range w.operations {
+		if n < op.weight {
+			return op.name
+		}
+		n -= op.weight
+	}
+	return w.operations[len(w.operations)-1].name
+}
+
+// runWorkload runs a mixed OLTP workload against the generated tables until the
+// duration has elapsed. If no duration has been provided, it runs until the process is killed.
+func (opt *GeneratorOptions) runWorkload() error {
+	startingTime := time.Now()
+
+	mix, err := parseWorkloadMix(opt.workloadMix)
+	if err != nil {
+		return err
+	}
+	if opt.binlogFile != "" {
+		return opt.writeBinlog(mix)
+	}
+	if opt.rate != "" {
+		limiter, err = parseRate(opt.rate)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = opt.prepareDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if opt.ledger != "" {
+		writeLedger, err = openLedger(opt.ledger, opt.overwrite)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := writeLedger.close(); err != nil {
+				out.error(err, "Failed to close the ledger")
+			}
+		}()
+	}
+
+	// the highest id of each table. the ids of the operations are picked from [1, maxID].
+	maxIDs := make([]int64, opt.tableNumber)
+	for i := range maxIDs {
+		err := db.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM table%d", i)).Scan(&maxIDs[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	out.phase("workload", "Running workload......................")
+	collector := newStatsCollector()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shutdown := notifyShutdown(cancel)
+	defer shutdown.stop()
+	budget := newErrorBudget(opt.maxErrors, cancel)
+	wg := sync.WaitGroup{}
+	for i := 0; i < opt.concurrency; i++ {
+		wg.Add(1)
+		recorder := collector.newRecorder()
+		go func() {
+			defer wg.Done()
+			metrics.workerStarted()
+			defer metrics.workerStopped()
+			if err := opt.runOperations(ctx, mix, maxIDs, recorder, budget); err != nil {
+				budget.abort(err)
+			}
+		}()
+	}
+
+	// report the statistics periodically
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		collector.reportStats(ctx, opt.reportInterval)
+	}()
+
+	// stop the workers after the duration has elapsed
+	if opt.duration > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			timer := time.NewTimer(opt.duration)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+			case <-timer.C:
+				out.phase("stopping", "Stopping workload...")
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := budget.failure(); err != nil {
+		return err
+	}
+
+	totalTime := time.Since(startingTime)
+	out.text("\n=========================== Summery ===========================\n")
+	out.summary([]summaryField{
+		{key: "durationSeconds", title: "Total time taken", text: totalTime.String(), value: totalTime.Seconds()},
+		{key: "failedStatements", title: "Failed statements", text: strconv.FormatInt(budget.failed(), 10), value: budget.failed()},
+	})
+	out.text("\n")
+	stats, elapsed := collector.summary()
+	printStats(StatsScopeTotal, stats, elapsed)
+	if opt.outageTolerance > 0 || outages.observed() {
+		outages.report()
+	}
+	return shutdown.interrupted()
+}
+
+// runOperations runs random operations until the context is cancelled. It returns
+// an error if the run has to be aborted due to the failed statements.
+func (opt *GeneratorOptions) runOperations(ctx context.Context, mix *workloadMix, maxIDs []int64, recorder *statsRecorder, budget *errorBudget) error {
+	// each worker has its own source as the global one is guarded by a lock
+	r := rand.New(rand.NewSource(rand.Int63()))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			table := r.Intn(opt.tableNumber)
+			tableName := fmt.Sprintf("table%d", table)
+			op := mix.pick(r)
+
+			statement, id := newWorkloadStatement(r, op, tableName, atomic.LoadInt64(&maxIDs[table]))
+			if limiter != nil {
+				if err := limiter.wait(ctx, limiter.cost(statement)); err != nil {
+					return nil
+				}
+			}
+
+			var rows, insertID int64
+			start := time.Now()
+			err := opt.execWithRetry(ctx, isWriteOperation(op), func() error {
+				var err error
+				rows, insertID, err = executeOperation(op, statement, &maxIDs[table])
+				return err
+			})
+			recorder.record(op, tableName, time.Since(start), rows, int64(len(statement)), err)
+			if err != nil {
+				out.error(err, "Failed to perform %s operation on table: %s", op, tableName)
+				if err := budget.add(err); err != nil {
+					return err
+				}
+				continue
+			}
+			switch {
+			case op == OpInsert:
+				writeLedger.record(LedgerInsert, tableName, insertID)
+			case op == OpDelete && rows > 0:
+				writeLedger.record(LedgerDelete, tableName, id)
+			}
+		}
+	}
+}
+
+// newWorkloadStatement returns the statement to execute for the operation along
+// with the id of the row it targets
+func newWorkloadStatement(r *rand.Rand, op, tableName string, maxID int64) (string, int64) {
+	id := int64(1)
+	if maxID > 0 {
+		id = 1 + r.Int63n(maxID)
+	}
+	switch op {
+	case OpSelect:
+		return fmt.Sprintf("SELECT id,name,height,weight,age,description FROM %s WHERE id = %d", tableName, id), id
+	case OpRange:
+		return fmt.Sprintf("SELECT id,name,height,weight,age FROM %s WHERE id BETWEEN %d AND %d", tableName, id, id+rangeScanSize-1), id
+	case OpUpdate:
+		return fmt.Sprintf("UPDATE %s SET %s WHERE id = %d", tableName, randomAssignment(r), id), id
+	case OpDelete:
+		return fmt.Sprintf("DELETE FROM %s WHERE id = %d", tableName, id), id
+	default:
+		return generator.InsertStatement(tableName, generator.NewRow(r)), 0
+	}
+}
+
+// randomAssignment returns an assignment of a random value to a random column
+func randomAssignment(r *rand.Rand) string {
+	column, value := randomColumnValue(r)
+	if name, ok := value.(string); ok {
+		return fmt.Sprintf("%s = %q", column, name)
+	}
+	return fmt.Sprintf("%s = %d", column, value)
+}
+
+// randomColumnValue returns a random column that is updated by the workload along with a random value
+func randomColumnValue(r *rand.Rand) (string, interface{}) {
+	switch r.Intn(4) {
+	case 0:
+		return "name", generator.Name(r)
+	case 1:
+		return "height", 120 + r.Int()%81
+	case 2:
+		return "weight", 30 + r.Int()%201
+	default:
+		return "age", 10 + r.Int()%101
+	}
+}
+
+// executeOperation executes the statement and returns the number of rows read or
+// affected and, for inserts, the id of the inserted row
+func executeOperation(op, statement string, maxID *int64) (int64, int64, error) {
+	switch op {
+	case OpSelect, OpRange:
+		rows, err := db.Query(statement)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer rows.Close()
+		var n int64
+		for rows.Next() {
+			n++
+		}
+		return n, 0, rows.Err()
+	default:
+		res, err := db.Exec(statement)
+		if err != nil {
+			return 0, 0, err
+		}
+		var id int64
+		if op == OpInsert {
+			if id, err = res.LastInsertId(); err == nil {
+				updateMaxID(maxID, id)
+			}
+		}
+		n, _ := res.RowsAffected()
+		return n, id, nil
+	}
+}
+
+// updateMaxID raises the highest known id of a table
+func updateMaxID(maxID *int64, id int64) {
+	for {
+		cur := atomic.LoadInt64(maxID)
+		if id <= cur || atomic.CompareAndSwapInt64(maxID, cur, id) {
+			return
+		}
+	}
+}